@@ -0,0 +1,367 @@
+package buffer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrUnknownDelivery 确认的投递不存在 已经确认过或者已超时被重新投递
+var ErrUnknownDelivery = errors.New("unknown delivery")
+
+// AckPool 存放interface{}数据的确认缓冲池
+type AckPool = AckPoolOf[interface{}]
+
+// Delivery 存放interface{}数据的投递
+type Delivery = DeliveryOf[interface{}]
+
+// DeliveryOf 一次投递 Get 返回 处理完后必须调用 Ack 或 Nack
+type DeliveryOf[T any] struct {
+	// ID 代表投递的编号 每次投递都不同 超时重新投递后旧的编号失效
+	ID uint64
+	// Value 代表投递的数据
+	Value T
+	// Deliveries 代表数据被投递的次数 包括本次
+	Deliveries uint32
+	pool       *AckPoolOf[T]
+}
+
+// Ack 确认数据已处理完成 数据从缓冲池中删除
+func (d *DeliveryOf[T]) Ack() error {
+	return d.pool.Ack(d.ID)
+}
+
+// Nack 表示数据处理失败 数据重新放回缓冲池
+func (d *DeliveryOf[T]) Nack() error {
+	return d.pool.Nack(d.ID)
+}
+
+// ackItem 确认缓冲池中的数据和它已被投递的次数
+type ackItem[T any] struct {
+	value      T
+	deliveries uint32
+}
+
+// inflight 已投递还没有确认的数据
+type inflight[T any] struct {
+	item     ackItem[T]
+	deadline time.Time
+}
+
+// AckPoolOf 确认缓冲池
+// Get 返回一次投递 数据在确认之前处于投递中 不会被其它 Get 取到
+// Ack 删除数据 Nack 或者超过visibility没有确认时数据重新放回缓冲池
+// 投递次数达到maxDeliveries的数据不再放回 而是放入死信缓冲池
+type AckPoolOf[T any] struct {
+	// pool 代表存放待投递数据的缓冲池
+	pool *BufferPoolOf[ackItem[T]]
+	// visibility 代表投递后等待确认的时间
+	visibility time.Duration
+	// maxDeliveries 代表数据最多被投递的次数 为0时不限制
+	maxDeliveries uint32
+	// deadLetter 代表死信缓冲池 为nil时丢弃超过投递次数的数据
+	deadLetter IPoolOf[T]
+	// lock 保护inflight nextID和err
+	lock sync.Mutex
+	// inflight 代表投递中的数据 按投递编号索引
+	inflight map[uint64]*inflight[T]
+	// nextID 代表上一次投递的编号
+	nextID uint64
+	// err 代表最近一次放入死信缓冲池遇到的错误
+	err error
+	// done 在缓冲池关闭时被关闭 用于结束超时检查协程
+	done chan struct{}
+
+	// acked 代表确认的数据数量
+	acked uint64
+	// redelivered 代表重新放回缓冲池的数据数量
+	redelivered uint64
+	// deadLettered 代表超过投递次数的数据数量
+	deadLettered uint64
+}
+
+// NewAckPool 用于创建一个存放interface{}数据的确认缓冲池 参数含义同NewAckPoolOf
+func NewAckPool(poolCap uint32, bufferCap uint32, visibility time.Duration, maxDeliveries uint32,
+	deadLetter IPool) (*AckPool, error) {
+	return NewAckPoolOf[interface{}](poolCap, bufferCap, visibility, maxDeliveries, deadLetter)
+}
+
+// NewAckPoolOf 用于创建一个存放T类型数据的确认缓冲池
+// poolCap bufferCap 含义同NewPool visibility为投递后等待确认的时间
+// maxDeliveries为数据最多被投递的次数 为0时不限制 deadLetter为死信缓冲池 可以为nil
+func NewAckPoolOf[T any](poolCap uint32, bufferCap uint32, visibility time.Duration, maxDeliveries uint32,
+	deadLetter IPoolOf[T]) (*AckPoolOf[T], error) {
+	if visibility <= 0 {
+		errMsg := fmt.Sprintf("invalid params visibility(%v) must be positive", visibility)
+		return nil, errors.New(errMsg)
+	}
+	pool, err := NewPoolOf[ackItem[T]](poolCap, bufferCap)
+	if err != nil {
+		return nil, err
+	}
+	ackPool := &AckPoolOf[T]{
+		pool:          pool,
+		visibility:    visibility,
+		maxDeliveries: maxDeliveries,
+		deadLetter:    deadLetter,
+		inflight:      make(map[uint64]*inflight[T]),
+		done:          make(chan struct{}),
+	}
+	go ackPool.checkLoop()
+	return ackPool, nil
+}
+
+var ackFmtMsg = "cap(%d) len(%d) bufCap(%d) total(%d) inflight(%d)"
+
+func (pool *AckPoolOf[T]) String() string {
+	return fmt.Sprintf(ackFmtMsg, pool.Cap(), pool.Len(), pool.BufferCap(), pool.Total(), pool.InFlight())
+}
+
+func (pool *AckPoolOf[T]) Cap() uint32 {
+	return pool.pool.Cap()
+}
+
+func (pool *AckPoolOf[T]) Len() uint32 {
+	return pool.pool.Len()
+}
+
+func (pool *AckPoolOf[T]) BufferCap() uint32 {
+	return pool.pool.BufferCap()
+}
+
+// Total 获取等待投递的数据总数 不包括投递中的数据
+func (pool *AckPoolOf[T]) Total() uint64 {
+	return pool.pool.Total()
+}
+
+// InFlight 获取投递中还没有确认的数据数量
+func (pool *AckPoolOf[T]) InFlight() int {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	return len(pool.inflight)
+}
+
+// Acked 获取确认的数据数量
+func (pool *AckPoolOf[T]) Acked() uint64 {
+	return atomic.LoadUint64(&pool.acked)
+}
+
+// Redelivered 获取 Nack 或者超时后重新放回缓冲池的数据数量
+func (pool *AckPoolOf[T]) Redelivered() uint64 {
+	return atomic.LoadUint64(&pool.redelivered)
+}
+
+// DeadLettered 获取超过投递次数 成功放入死信缓冲池或者没有死信缓冲池时被丢弃的数据数量
+func (pool *AckPoolOf[T]) DeadLettered() uint64 {
+	return atomic.LoadUint64(&pool.deadLettered)
+}
+
+func (pool *AckPoolOf[T]) Put(data T) (ok bool, err error) {
+	return pool.pool.Put(ackItem[T]{value: data})
+}
+
+func (pool *AckPoolOf[T]) TryPut(data T) (ok bool, err error) {
+	return pool.pool.TryPut(ackItem[T]{value: data})
+}
+
+func (pool *AckPoolOf[T]) PutContext(ctx context.Context, data T) (ok bool, err error) {
+	return pool.pool.PutContext(ctx, ackItem[T]{value: data})
+}
+
+func (pool *AckPoolOf[T]) PutTimeout(data T, timeout time.Duration) (ok bool, err error) {
+	return pool.pool.PutTimeout(ackItem[T]{value: data}, timeout)
+}
+
+func (pool *AckPoolOf[T]) PutBatch(items []T) (n int, err error) {
+	batch := make([]ackItem[T], len(items))
+	for i, data := range items {
+		batch[i].value = data
+	}
+	return pool.pool.PutBatch(batch)
+}
+
+// Get 阻塞地获取一次投递 直到有数据或者缓冲池关闭
+func (pool *AckPoolOf[T]) Get() (*DeliveryOf[T], error) {
+	return pool.GetContext(context.Background())
+}
+
+// GetContext 阻塞地获取一次投递 ctx 结束时返回ctx.Err()
+func (pool *AckPoolOf[T]) GetContext(ctx context.Context) (*DeliveryOf[T], error) {
+	item, err := pool.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return pool.deliver(item), nil
+}
+
+func (pool *AckPoolOf[T]) GetTimeout(timeout time.Duration) (*DeliveryOf[T], error) {
+	item, err := pool.pool.GetTimeout(timeout)
+	if err != nil {
+		return nil, err
+	}
+	return pool.deliver(item), nil
+}
+
+// TryGet 非阻塞地获取一次投递 缓冲池为空时返回ErrBufferEmpty
+func (pool *AckPoolOf[T]) TryGet() (*DeliveryOf[T], error) {
+	item, err := pool.pool.TryGet()
+	if err != nil {
+		return nil, err
+	}
+	return pool.deliver(item), nil
+}
+
+// GetBatch 非阻塞地批量获取最多max次投递
+func (pool *AckPoolOf[T]) GetBatch(max int) ([]*DeliveryOf[T], error) {
+	items, err := pool.pool.GetBatch(max)
+	if err != nil {
+		return nil, err
+	}
+	deliveries := make([]*DeliveryOf[T], len(items))
+	for i, item := range items {
+		deliveries[i] = pool.deliver(item)
+	}
+	return deliveries, nil
+}
+
+// deliver 把取出的数据登记为投递中
+func (pool *AckPoolOf[T]) deliver(item ackItem[T]) *DeliveryOf[T] {
+	item.deliveries++
+	pool.lock.Lock()
+	pool.nextID++
+	id := pool.nextID
+	pool.inflight[id] = &inflight[T]{item: item, deadline: time.Now().Add(pool.visibility)}
+	pool.lock.Unlock()
+	return &DeliveryOf[T]{ID: id, Value: item.value, Deliveries: item.deliveries, pool: pool}
+}
+
+// Ack 确认投递id的数据已处理完成
+// 投递不存在(已确认或者已超时)时返回ErrUnknownDelivery
+func (pool *AckPoolOf[T]) Ack(id uint64) error {
+	pool.lock.Lock()
+	_, ok := pool.inflight[id]
+	delete(pool.inflight, id)
+	pool.lock.Unlock()
+	if !ok {
+		return ErrUnknownDelivery
+	}
+	atomic.AddUint64(&pool.acked, 1)
+	return nil
+}
+
+// Nack 表示投递id的数据处理失败 数据立即重新放回缓冲池 超过投递次数的放入死信缓冲池
+// 投递不存在(已确认或者已超时)时返回ErrUnknownDelivery
+func (pool *AckPoolOf[T]) Nack(id uint64) error {
+	pool.lock.Lock()
+	f, ok := pool.inflight[id]
+	delete(pool.inflight, id)
+	pool.lock.Unlock()
+	if !ok {
+		return ErrUnknownDelivery
+	}
+	if !pool.requeue(f.item) {
+		pool.retryLater(f.item)
+	}
+	return nil
+}
+
+// requeue 把处理失败的数据放回缓冲池或者死信缓冲池 返回false表示放不进去需要稍后重试
+// 放入死信缓冲池失败时数据保留 错误记录在err中
+func (pool *AckPoolOf[T]) requeue(item ackItem[T]) bool {
+	if pool.maxDeliveries > 0 && item.deliveries >= pool.maxDeliveries {
+		if pool.deadLetter != nil {
+			if _, err := pool.deadLetter.TryPut(item.value); err != nil {
+				if err != ErrBufferOverload {
+					pool.lock.Lock()
+					pool.err = err
+					pool.lock.Unlock()
+				}
+				return false
+			}
+		}
+		atomic.AddUint64(&pool.deadLettered, 1)
+		return true
+	}
+	_, err := pool.pool.TryPut(item)
+	if err == ErrBufferOverload {
+		return false
+	}
+	if err == nil {
+		atomic.AddUint64(&pool.redelivered, 1)
+	}
+	return true
+}
+
+// Err 获取最近一次放入死信缓冲池遇到的错误 例如死信缓冲池已关闭
+// 放不进死信缓冲池的数据留在投递中 由超时检查协程定期重试
+func (pool *AckPoolOf[T]) Err() error {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	return pool.err
+}
+
+// retryLater 放不回去的数据用新的编号保留在投递中 由超时检查协程重试
+func (pool *AckPoolOf[T]) retryLater(item ackItem[T]) {
+	pool.lock.Lock()
+	pool.nextID++
+	pool.inflight[pool.nextID] = &inflight[T]{item: item}
+	pool.lock.Unlock()
+}
+
+// checkLoop 定期把超时没有确认的数据放回缓冲池 直到缓冲池关闭
+func (pool *AckPoolOf[T]) checkLoop() {
+	interval := pool.visibility / 2
+	if interval < time.Millisecond {
+		interval = time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			pool.requeueExpired(time.Now())
+		case <-pool.done:
+			return
+		}
+	}
+}
+
+// requeueExpired 把在now之前超时的投递重新放回缓冲池 放不回去的留到下次
+func (pool *AckPoolOf[T]) requeueExpired(now time.Time) {
+	var expired []ackItem[T]
+	pool.lock.Lock()
+	for id, f := range pool.inflight {
+		if !now.Before(f.deadline) {
+			expired = append(expired, f.item)
+			delete(pool.inflight, id)
+		}
+	}
+	pool.lock.Unlock()
+
+	for _, item := range expired {
+		if !pool.requeue(item) {
+			pool.retryLater(item)
+		}
+	}
+}
+
+// Stats 获取缓冲池的运行统计快照 Total 不包括投递中的数据
+func (pool *AckPoolOf[T]) Stats() PoolStats {
+	return pool.pool.Stats()
+}
+
+// Close 关闭缓冲池 投递中的数据不再重新投递 之后仍可以 Ack
+func (pool *AckPoolOf[T]) Close() bool {
+	if !pool.pool.Close() {
+		return false
+	}
+	close(pool.done)
+	return true
+}
+
+func (pool *AckPoolOf[T]) Closed() bool {
+	return pool.pool.Closed()
+}
@@ -13,51 +13,85 @@ var ErrClosedBuffer = errors.New("closed buffer")
 //ErrBufferOverload 缓冲器已满 过载了
 var ErrBufferOverload = errors.New("buffer is overload")
 
-type IBuffer interface {
-	Cap() uint32                        // Cap 用于获取本缓冲器的容量
-	Len() uint32                        // Len 用于获取本缓冲器中的数据数量。
-	Put(data interface{}) (bool, error) // Put 用于向缓冲器放入数据。
-	Get() (interface{}, error)          // Get 用于从缓冲器获取器。
-	Close() bool                        // Close 用于关闭缓冲器。 若缓冲器之前已关闭则返回false，否则返回true。
-	Closed() bool                       // Closed 用于判断缓冲器是否已关闭。
+// ErrBufferEmpty 缓冲器中没有数据
+var ErrBufferEmpty = errors.New("buffer is empty")
+
+// IBufferOf 缓冲器接口 T为缓冲器中存放的数据类型
+type IBufferOf[T any] interface {
+	Cap() uint32                     // Cap 用于获取本缓冲器的容量
+	Len() uint32                     // Len 用于获取本缓冲器中的数据数量。
+	Put(data T) (bool, error)        // Put 用于向缓冲器放入数据。
+	Get() (T, error)                 // Get 用于从缓冲器获取数据 没有数据时返回ErrBufferEmpty。
+	PutBatch(items []T) (int, error) // PutBatch 用于向缓冲器批量放入数据 返回放入的数量 未全部放入时返回ErrBufferOverload。
+	GetBatch(max int) ([]T, error)   // GetBatch 用于从缓冲器批量获取最多max个数据 一个都没有时返回ErrBufferEmpty。
+	Peek() (T, error)                // Peek 用于查看下一个被 Get 的数据但不取出 没有数据时返回ErrBufferEmpty。
+	PeekN(n int) ([]T, error)        // PeekN 用于按 Get 的顺序查看最多n个数据但不取出 一个都没有时返回ErrBufferEmpty。
+	Range(fn func(data T) bool)      // Range 用于按 Get 的顺序依次查看缓冲器中的所有数据 fn返回false时停止。
+	Close() bool                     // Close 用于关闭缓冲器。 若缓冲器之前已关闭则返回false，否则返回true。
+	Closed() bool                    // Closed 用于判断缓冲器是否已关闭。
 }
 
-// Buffer 代表缓冲器接口的实现类型。
-type Buffer struct {
+// IBuffer 存放interface{}数据的缓冲器接口
+type IBuffer = IBufferOf[interface{}]
+
+// Buffer 存放interface{}数据的缓冲器
+type Buffer = BufferOf[interface{}]
+
+// BufferOf 代表缓冲器接口的实现类型。
+type BufferOf[T any] struct {
 	// ch 代表存放数据的通道。
-	ch chan interface{}
+	ch chan T
 	// closed 代表缓冲器的关闭状态：0-未关闭；1-已关闭。
 	closed uint32
-	// closingLock 代表为了消除因关闭缓冲器而产生的竞态条件的读写锁。
+	// closingLock 代表为了消除因关闭缓冲器和查看数据而产生的竞态条件的读写锁。
 	closingLock sync.RWMutex
+	// head 代表查看数据时从通道中取出的数据 比通道中的数据先被取走
+	head []T
+	// peeked 代表head中的数据数量 不为0时 Put Get 需要持有headLock
+	peeked int32
+	// headLock 代表保护head的互斥锁。
+	headLock sync.Mutex
 }
 
 // NewBuffer 用于创建一个缓冲器。参数size代表缓冲器的容量。
 func NewBuffer(size uint32) (IBuffer, error) {
+	return NewBufferOf[interface{}](size)
+}
+
+// NewBufferOf 用于创建一个存放T类型数据的缓冲器。参数size代表缓冲器的容量。
+func NewBufferOf[T any](size uint32) (IBufferOf[T], error) {
 	if size == 0 {
 		errMsg := fmt.Sprintf("illegal size for buffer: %d", size)
 		return nil, errors.New(errMsg)
 	}
-	return &Buffer{
-		ch: make(chan interface{}, size),
+	return &BufferOf[T]{
+		ch: make(chan T, size),
 	}, nil
 }
 
-func (buf *Buffer) Cap() uint32 {
+func (buf *BufferOf[T]) Cap() uint32 {
 	return uint32(cap(buf.ch))
 }
 
-func (buf *Buffer) Len() uint32 {
-	return uint32(len(buf.ch))
+func (buf *BufferOf[T]) Len() uint32 {
+	return uint32(len(buf.ch) + int(atomic.LoadInt32(&buf.peeked)))
 }
 
-func (buf *Buffer) Put(data interface{}) (ok bool, err error) {
+func (buf *BufferOf[T]) Put(data T) (ok bool, err error) {
 	//加锁处理  防止buf.ch被关闭时 出错
 	buf.closingLock.RLock()
 	defer buf.closingLock.RUnlock()
 	if buf.Closed() {
 		return false, ErrClosedBuffer
 	}
+	//head中的数据也占用容量
+	if atomic.LoadInt32(&buf.peeked) > 0 {
+		buf.headLock.Lock()
+		defer buf.headLock.Unlock()
+		if len(buf.ch)+len(buf.head) >= cap(buf.ch) {
+			return false, ErrBufferOverload
+		}
+	}
 
 	select {
 	case buf.ch <- data:
@@ -69,19 +103,157 @@ func (buf *Buffer) Put(data interface{}) (ok bool, err error) {
 	return
 }
 
-func (buf *Buffer) Get() (interface{}, error) {
+func (buf *BufferOf[T]) Get() (data T, err error) {
+	//加锁处理 防止查看数据时 取出的顺序被打乱
+	buf.closingLock.RLock()
+	defer buf.closingLock.RUnlock()
+	if atomic.LoadInt32(&buf.peeked) > 0 {
+		buf.headLock.Lock()
+		if len(buf.head) > 0 {
+			data = buf.popHead()
+			buf.headLock.Unlock()
+			return data, nil
+		}
+		buf.headLock.Unlock()
+	}
 	select {
-	case data, ok := <-buf.ch:
+	case d, ok := <-buf.ch:
 		if !ok {
-			return nil, ErrClosedBuffer
+			return data, ErrClosedBuffer
 		}
-		return data, nil
+		return d, nil
 	default:
-		return nil, errors.New("nothing")
+		return data, ErrBufferEmpty
+	}
+}
+
+func (buf *BufferOf[T]) PutBatch(items []T) (n int, err error) {
+	buf.closingLock.RLock()
+	defer buf.closingLock.RUnlock()
+	if buf.Closed() {
+		return 0, ErrClosedBuffer
+	}
+	if atomic.LoadInt32(&buf.peeked) > 0 {
+		buf.headLock.Lock()
+		defer buf.headLock.Unlock()
+		if free := cap(buf.ch) - len(buf.ch) - len(buf.head); free < len(items) {
+			if n, _ = buf.putBatch(items[:free]); n == free {
+				err = ErrBufferOverload
+			}
+			return
+		}
+	}
+	return buf.putBatch(items)
+}
+
+// putBatch 向通道批量放入数据 放不下时返回ErrBufferOverload
+func (buf *BufferOf[T]) putBatch(items []T) (n int, err error) {
+	for _, data := range items {
+		select {
+		case buf.ch <- data:
+			n++
+		default:
+			return n, ErrBufferOverload
+		}
+	}
+	return n, nil
+}
+
+func (buf *BufferOf[T]) GetBatch(max int) (items []T, err error) {
+	buf.closingLock.RLock()
+	defer buf.closingLock.RUnlock()
+	if atomic.LoadInt32(&buf.peeked) > 0 {
+		buf.headLock.Lock()
+		for len(items) < max && len(buf.head) > 0 {
+			items = append(items, buf.popHead())
+		}
+		buf.headLock.Unlock()
+	}
+	for len(items) < max {
+		select {
+		case data, ok := <-buf.ch:
+			if !ok {
+				if len(items) == 0 {
+					err = ErrClosedBuffer
+				}
+				return
+			}
+			items = append(items, data)
+		default:
+			if len(items) == 0 {
+				err = ErrBufferEmpty
+			}
+			return
+		}
+	}
+	return
+}
+
+func (buf *BufferOf[T]) Peek() (data T, err error) {
+	items, err := buf.PeekN(1)
+	if err != nil {
+		return data, err
+	}
+	return items[0], nil
+}
+
+// PeekN 查看数据时只从通道中取出还没有查看过的前n个数据 放在head中 之后仍然先被 Get 取走
+// 期间 Put Get 短暂等待 缓冲器关闭后仍可以查看剩余的数据 取完后返回ErrClosedBuffer
+func (buf *BufferOf[T]) PeekN(n int) (items []T, err error) {
+	buf.closingLock.Lock()
+	defer buf.closingLock.Unlock()
+	if n <= 0 {
+		return nil, nil
+	}
+
+loop:
+	for len(buf.head) < n {
+		select {
+		case data, ok := <-buf.ch:
+			if !ok {
+				break loop
+			}
+			buf.head = append(buf.head, data)
+		default:
+			break loop
+		}
+	}
+	atomic.StoreInt32(&buf.peeked, int32(len(buf.head)))
+	if len(buf.head) == 0 {
+		if buf.Closed() {
+			return nil, ErrClosedBuffer
+		}
+		return nil, ErrBufferEmpty
+	}
+	if n > len(buf.head) {
+		n = len(buf.head)
+	}
+	return append([]T(nil), buf.head[:n]...), nil
+}
+
+// popHead 取出head中的第一个数据 调用方必须持有headLock并保证head非空
+func (buf *BufferOf[T]) popHead() T {
+	var zero T
+	data := buf.head[0]
+	buf.head[0] = zero
+	if buf.head = buf.head[1:]; len(buf.head) == 0 {
+		buf.head = nil
+	}
+	atomic.StoreInt32(&buf.peeked, int32(len(buf.head)))
+	return data
+}
+
+// Range 先复制所有的数据再依次调用fn 调用fn时不持有锁 fn中可以访问本缓冲器
+func (buf *BufferOf[T]) Range(fn func(data T) bool) {
+	items, _ := buf.PeekN(int(buf.Cap()))
+	for _, data := range items {
+		if !fn(data) {
+			return
+		}
 	}
 }
 
-func (buf *Buffer) Close() bool {
+func (buf *BufferOf[T]) Close() bool {
 	buf.closingLock.Lock()
 	if atomic.CompareAndSwapUint32(&buf.closed, 0, 1) {
 		close(buf.ch)
@@ -92,7 +264,7 @@ func (buf *Buffer) Close() bool {
 	return false
 }
 
-func (buf *Buffer) Closed() bool {
+func (buf *BufferOf[T]) Closed() bool {
 	if atomic.LoadUint32(&buf.closed) == 0 {
 		return false
 	}
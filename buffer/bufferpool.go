@@ -1,16 +1,20 @@
 package buffer
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"math"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 // ErrClosedBufferPool 是表示缓冲池已关闭的错误的变量。
 var ErrClosedBufferPool = errors.New("pool is closed")
 
-type IPool interface {
+// IPoolOf 缓冲池接口 T为缓冲池中存放的数据类型
+type IPoolOf[T any] interface {
 	//Cap 内存池中缓冲器的数量
 	Cap() uint32
 	//Len() 用于获取内存池中缓冲器的使用的数量
@@ -20,13 +24,44 @@ type IPool interface {
 	// Total 用于获取缓冲池中数据的总数
 	Total() uint64
 	// Put 用于向缓冲池放入数据
-	// 注意！本方法应该是阻塞的
+	// 注意！本方法是阻塞的 缓冲池已满时会一直等到有空间为止
 	// 若缓冲池已关闭则会直接返回非nil的错误值
-	Put(data interface{}) (ok bool, err error)
+	Put(data T) (ok bool, err error)
 	// Get 用于从缓冲池获取数据
-	// 注意！本方法应该是阻塞的
+	// 注意！本方法是阻塞的 缓冲池为空时会一直等到有数据为止
 	// 若缓冲池已关闭则会直接返回非nil的错误值
-	Get() (data interface{}, err error)
+	Get() (data T, err error)
+	// TryPut 用于向缓冲池放入数据 非阻塞
+	// 缓冲池已满时返回ErrBufferOverload
+	TryPut(data T) (ok bool, err error)
+	// TryGet 用于从缓冲池获取数据 非阻塞
+	// 缓冲池为空时返回ErrBufferEmpty
+	TryGet() (data T, err error)
+	// PutContext 阻塞地放入数据 ctx 结束时返回ctx.Err()
+	PutContext(ctx context.Context, data T) (ok bool, err error)
+	// GetContext 阻塞地获取数据 ctx 结束时返回ctx.Err()
+	GetContext(ctx context.Context) (data T, err error)
+	// PutTimeout 阻塞地放入数据 超时返回context.DeadlineExceeded
+	PutTimeout(data T, timeout time.Duration) (ok bool, err error)
+	// GetTimeout 阻塞地获取数据 超时返回context.DeadlineExceeded
+	GetTimeout(timeout time.Duration) (data T, err error)
+	// PutBatch 用于向缓冲池批量放入数据 非阻塞 返回放入的数量
+	// 未能全部放入时返回ErrBufferOverload
+	PutBatch(items []T) (n int, err error)
+	// GetBatch 用于从缓冲池批量获取最多max个数据 非阻塞
+	// 一个都没有取到时返回ErrBufferEmpty
+	GetBatch(max int) (items []T, err error)
+	// Peek 用于查看下一个被 Get 的数据但不取出 非阻塞
+	// 缓冲池为空时返回ErrBufferEmpty
+	Peek() (data T, err error)
+	// PeekN 用于尽量按 Get 的顺序查看最多n个数据但不取出 非阻塞 具体的顺序见各实现的说明
+	// 一个都没有时返回ErrBufferEmpty
+	PeekN(n int) (items []T, err error)
+	// Range 用于依次查看缓冲池中的所有数据 fn返回false时停止
+	// 可以和 Put Get 并发调用 具体的一致性见各实现的说明
+	Range(fn func(data T) bool)
+	// Stats 用于获取缓冲池的运行统计快照
+	Stats() PoolStats
 	// Close 用于关闭缓冲池。
 	// 若缓冲池之前已关闭则返回false，否则返回true。
 	Close() bool
@@ -34,8 +69,14 @@ type IPool interface {
 	Closed() bool
 }
 
-// BufferPool 代表数据缓冲池接口的实现类型。
-type BufferPool struct {
+// IPool 存放interface{}数据的缓冲池接口
+type IPool = IPoolOf[interface{}]
+
+// BufferPool 存放interface{}数据的缓冲池
+type BufferPool = BufferPoolOf[interface{}]
+
+// BufferPoolOf 代表数据缓冲池接口的实现类型。
+type BufferPoolOf[T any] struct {
 	// poolCap 代表缓冲器的最大数量。
 	poolCap uint32
 	// poolSize 代表缓冲器的实际数量。
@@ -44,12 +85,83 @@ type BufferPool struct {
 	bufferCap uint32
 	// total 代表池中数据的总数。
 	total uint64
-	// bufChs 代表存放缓冲器的通道。
-	bufChs chan IBuffer
+	// bufChs 代表存放缓冲器的通道 容量为maxPoolCap。
+	bufChs chan IBufferOf[T]
+	// maxPoolCap 代表SetPoolCap可以设置的最大值 默认为DefaultMaxPoolCap和创建时的poolCap中较大的
+	maxPoolCap uint32
+	// bufCapActual 代表按当前bufferCap创建的缓冲器的实际容量 容量不同的空缓冲器会被淘汰
+	bufCapActual uint32
 	// closed 代表缓冲池的关闭状态：0-未关闭；1-已关闭。
 	closed uint32
 	// lock 代表保护内部共享资源的读写锁。
 	rwlock sync.RWMutex
+	// putSignal 用于唤醒等待空间的 Put 调用者。
+	putSignal *signal
+	// getSignal 用于唤醒等待数据的 Get 调用者。
+	getSignal *signal
+	// done 在缓冲池关闭时被关闭 用于唤醒所有阻塞的调用者。
+	done chan struct{}
+
+	// ordered 代表是否为严格先进先出模式 此模式下缓冲器不经过bufChs 而是按顺序存放在segs中
+	ordered bool
+	// segs 代表先进先出模式下首尾相连的缓冲器链 segs[0]为链头
+	segs []IBufferOf[T]
+	// segLock 代表保护segs的互斥锁。
+	segLock sync.Mutex
+
+	// factory 代表创建缓冲器的工厂函数 默认为NewBufferOf
+	factory BufferFactory[T]
+	// minBuffers 代表缓冲器的最少数量 回收空缓冲器时不会低于该值
+	minBuffers uint32
+	// initBuffers 代表创建缓冲池时预先创建的缓冲器数量
+	initBuffers uint32
+	// growStep 代表缓冲器都已写满时一次新建的缓冲器数量
+	growStep uint32
+	// shrinkThreshold 代表回收空缓冲器的阈值 数据总数不超过该值时才回收
+	shrinkThreshold uint64
+
+	// priority 代表是否为优先级模式 此模式下 Get 在空闲的缓冲器中选择数据优先级最高的
+	priority bool
+	// starveLimit 代表优先级模式下低优先级的缓冲器最多连续被跳过的次数
+	starveLimit uint32
+	// prioSkipped 代表优先级模式下低优先级的缓冲器连续被跳过的次数
+	prioSkipped uint32
+
+	// sizer 代表计算数据字节数的函数 为nil时不统计字节数
+	sizer func(data T) uint64
+	// maxBytes 代表数据总字节数的上限 为0时不限制
+	maxBytes uint64
+	// bytes 代表池中数据的总字节数
+	bytes uint64
+
+	// totalMark 代表数据总数的水位线 为nil时不使用
+	totalMark *watermark
+	// bufferMark 代表缓冲器数量的水位线 为nil时不使用
+	bufferMark *watermark
+	// watermarkFn 代表越过水位线时的回调函数
+	watermarkFn func(e WatermarkEvent)
+	// watermarkCh 代表水位线通知通道
+	watermarkCh chan WatermarkEvent
+
+	// freezeLock 保证同一时刻只有一个调用者独占所有的缓冲器
+	freezeLock sync.Mutex
+
+	// draining 代表是否正在排空：0-否；1-是。排空时不再接受 Put
+	draining uint32
+	// putting 代表正在进行的 TryPut PutBatch 数量 排空时等待它们结束
+	putting int32
+
+	// spill 代表磁盘溢出日志 为nil时不使用
+	spill *SpillLog[T]
+
+	// overflow 代表缓冲池已满时 Put 的处理策略 为nil时等同于OverflowBlock
+	overflow atomic.Pointer[overflowConfig[T]]
+	// dropped 代表OverflowDropNewest策略下丢弃的数据数量
+	dropped uint64
+	// evicted 代表OverflowDropOldest策略下淘汰的数据数量
+	evicted uint64
+	// spilled 代表OverflowSpill策略下交给溢出处理函数的数据数量
+	spilled uint64
 
 	//putSize 存放了多少数据 用于测试
 	putSize uint64
@@ -57,60 +169,217 @@ type BufferPool struct {
 	getSize uint64
 	//newBufferCount  创建buffer多少次  用于测试
 	newBufferCount uint32
+
+	// overloads 代表放入时缓冲池已满的次数
+	overloads uint64
+	// created 代表创建缓冲器的总数 包括创建缓冲池时预先创建的
+	created uint64
+	// destroyed 代表回收缓冲器的总数
+	destroyed uint64
+	// highWater 代表数据总数的历史最大值
+	highWater uint64
+	// putWait 代表 Put 阻塞等待的累计时间 单位纳秒
+	putWait int64
+	// getWait 代表 Get 阻塞等待的累计时间 单位纳秒
+	getWait int64
 }
 
 // NewPool 用于创建一个数据缓冲池
 // 参数poolCap代表池中最多包含的缓冲器的数量
 // 参数bufferCap代表池内缓冲器的统一容量
 func NewPool(poolCap uint32, bufferCap uint32) (IPool, error) {
+	pool, err := NewPoolOf[interface{}](poolCap, bufferCap)
+	if err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
+
+// NewPoolOf 用于创建一个存放T类型数据的缓冲池 参数含义同NewPool
+// 返回具体类型 以便调用IPoolOf之外的扩展方法
+func NewPoolOf[T any](poolCap uint32, bufferCap uint32) (*BufferPoolOf[T], error) {
+	return NewPoolWithOptions[T](poolCap, bufferCap)
+}
+
+// NewPoolWithOptions 用于创建一个存放T类型数据的缓冲池 参数含义同NewPool
+// opts 用于定制缓冲器的创建方式以及缓冲池的伸缩策略 见PoolOption
+func NewPoolWithOptions[T any](poolCap uint32, bufferCap uint32, opts ...PoolOption[T]) (*BufferPoolOf[T], error) {
 	if poolCap == 0 || bufferCap == 0 {
 		errMsg := fmt.Sprintf("invalid params cannot eq 0 poolCap(%d) bufferCap(%d)", poolCap, bufferCap)
 		return nil, errors.New(errMsg)
 	}
 
-	buffer, err := NewBuffer(bufferCap)
+	pool := &BufferPoolOf[T]{
+		poolCap:         poolCap,
+		bufferCap:       bufferCap,
+		total:           0,
+		putSignal:       newSignal(),
+		getSignal:       newSignal(),
+		done:            make(chan struct{}),
+		factory:         NewBufferOf[T],
+		minBuffers:      1,
+		initBuffers:     1,
+		growStep:        1,
+		shrinkThreshold: math.MaxUint64,
+	}
+	for _, opt := range opts {
+		if err := opt(pool); err != nil {
+			return nil, err
+		}
+	}
+	if pool.maxPoolCap == 0 {
+		pool.maxPoolCap = DefaultMaxPoolCap
+	}
+	if pool.maxPoolCap < poolCap {
+		pool.maxPoolCap = poolCap
+	}
+	pool.bufChs = make(chan IBufferOf[T], pool.maxPoolCap)
+	if pool.ordered && pool.priority {
+		return nil, errors.New("invalid params ordered and priority cannot be used together")
+	}
+	if pool.minBuffers > pool.initBuffers || pool.initBuffers > poolCap {
+		errMsg := fmt.Sprintf("invalid params minBuffers(%d) <= initBuffers(%d) <= poolCap(%d)",
+			pool.minBuffers, pool.initBuffers, poolCap)
+		return nil, errors.New(errMsg)
+	}
+
+	for i := uint32(0); i < pool.initBuffers; i++ {
+		buffer, err := pool.newBuffer()
+		if err != nil {
+			return nil, err
+		}
+		if pool.ordered {
+			pool.segs = append(pool.segs, buffer)
+		} else {
+			pool.bufChs <- buffer
+		}
+	}
+	//初始创建的缓冲器不计入newBufferCount
+	pool.newBufferCount = 0
+	return pool, nil
+}
+
+// newBuffer 用工厂函数创建一个缓冲器 并计入缓冲器的数量
+func (pool *BufferPoolOf[T]) newBuffer() (IBufferOf[T], error) {
+	buf, err := pool.factory(pool.BufferCap())
 	if err != nil {
 		return nil, err
 	}
+	atomic.StoreUint32(&pool.bufCapActual, buf.Cap())
+	size := atomic.AddUint32(&pool.poolSize, 1)
+	atomic.AddUint32(&pool.newBufferCount, 1)
+	atomic.AddUint64(&pool.created, 1)
+	pool.checkBuffers(size)
+	return buf, nil
+}
 
-	bufChs := make(chan IBuffer, poolCap)
-	bufChs <- buffer
-
-	return &BufferPool{
-		poolCap:   poolCap,
-		poolSize:  1,
-		bufferCap: bufferCap,
-		total:     0,
-		bufChs:    bufChs,
-	}, nil
+// growSpare 按增长步长 在刚创建的缓冲器之外再预先创建空的缓冲器放入池中
+// 调用方必须持有写锁
+func (pool *BufferPoolOf[T]) growSpare() {
+	for i := uint32(1); i < pool.growStep && pool.Len() < pool.Cap(); i++ {
+		buf, err := pool.newBuffer()
+		if err != nil {
+			return
+		}
+		pool.bufChs <- buf
+	}
 }
 
 var fmtMsg = "cap(%d) len(%d) bufCap(%d) putSize(%d) getSize(%d) newBufCount(%d)"
 
-func (pool *BufferPool) String() string {
+func (pool *BufferPoolOf[T]) String() string {
 	return fmt.Sprintf(fmtMsg, pool.Cap(), pool.Len(), pool.BufferCap(), pool.putSize, pool.getSize, pool.newBufferCount)
 }
 
-func (pool *BufferPool) BufferCap() uint32 {
-	return pool.bufferCap
+func (pool *BufferPoolOf[T]) BufferCap() uint32 {
+	return atomic.LoadUint32(&pool.bufferCap)
 }
 
-func (pool *BufferPool) Cap() uint32 {
-	return pool.poolCap
+func (pool *BufferPoolOf[T]) Cap() uint32 {
+	return atomic.LoadUint32(&pool.poolCap)
 }
 
-func (pool *BufferPool) Len() uint32 {
+func (pool *BufferPoolOf[T]) Len() uint32 {
 	return atomic.LoadUint32(&pool.poolSize)
 }
 
-func (pool *BufferPool) Total() uint64 {
+func (pool *BufferPoolOf[T]) Total() uint64 {
 	return atomic.LoadUint64(&pool.total)
 }
 
-func (pool *BufferPool) Put(data interface{}) (ok bool, err error) {
+// Put 阻塞地放入数据 直到成功或者缓冲池关闭
+func (pool *BufferPoolOf[T]) Put(data T) (ok bool, err error) {
+	return pool.PutContext(context.Background(), data)
+}
+
+// PutTimeout 阻塞地放入数据 最多等待timeout
+func (pool *BufferPoolOf[T]) PutTimeout(data T, timeout time.Duration) (ok bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return pool.PutContext(ctx, data)
+}
+
+// PutContext 放入数据 缓冲池已满时按溢出策略处理 默认策略下等待Get腾出空间
+// ctx结束时返回ctx.Err() 缓冲池关闭时返回ErrClosedBufferPool
+func (pool *BufferPoolOf[T]) PutContext(ctx context.Context, data T) (ok bool, err error) {
+	err = pool.waitPut(ctx, nil, nil, func() (bool, error) {
+		if ok, err = pool.TryPut(data); err != ErrBufferOverload {
+			return true, err
+		}
+		var handled bool
+		ok, handled, err = pool.putOverflow(data)
+		return handled, err
+	})
+	return
+}
+
+// waitPut 反复调用try直到它返回true 每次返回false后等待 Get 腾出空间 并累计 Put 的等待时间
+// ctx结束时返回ctx.Err() 缓冲池关闭时返回ErrClosedBufferPool stop被关闭时返回stopErr stop可以为nil
+func (pool *BufferPoolOf[T]) waitPut(ctx context.Context, stop <-chan struct{}, stopErr error,
+	try func() (bool, error)) error {
+	return pool.putSignal.until(ctx, pool.done, ErrClosedBufferPool, stop, stopErr, &pool.putWait, try)
+}
+
+// waitGet 反复调用try直到它返回true 每次返回false后等待 Put 放入数据 并累计 Get 的等待时间
+// ctx结束时返回ctx.Err() 缓冲池关闭时返回ErrClosedBufferPool stop被关闭时返回stopErr stop可以为nil
+func (pool *BufferPoolOf[T]) waitGet(ctx context.Context, stop <-chan struct{}, stopErr error,
+	try func() (bool, error)) error {
+	return pool.getSignal.until(ctx, pool.done, ErrClosedBufferPool, stop, stopErr, &pool.getWait, try)
+}
+
+// TryPut 非阻塞地放入数据 所有缓冲器已满且数量已达上限时返回ErrBufferOverload
+// 设置了字节数上限时 放入后超过上限也返回ErrBufferOverload
+func (pool *BufferPoolOf[T]) TryPut(data T) (ok bool, err error) {
 	if pool.Closed() {
 		return false, ErrClosedBufferPool
 	}
+	//先检查排空 排空时溢出日志不为空也不能按已满处理 否则 PutContext 会把数据写入溢出日志
+	if !pool.beginPut() {
+		return false, ErrDrainingBufferPool
+	}
+	defer pool.endPut()
+	if pool.spilling() {
+		pool.putFinished(false, ErrBufferOverload)
+		return false, ErrBufferOverload
+	}
+	size := pool.sizeOf(data)
+	if !pool.reserveBytes(size) {
+		pool.putFinished(false, ErrBufferOverload)
+		return false, ErrBufferOverload
+	}
+	if ok, err = pool.tryPut(data); !ok {
+		pool.releaseBytes(size)
+	}
+	return
+}
+
+// tryPut 非阻塞地把数据放入缓冲器
+func (pool *BufferPoolOf[T]) tryPut(data T) (ok bool, err error) {
+	if pool.ordered {
+		ok, err = pool.putOrdered(data)
+		pool.putFinished(ok, err)
+		return
+	}
 
 	var count uint32
 	var tryTimes uint32 = pool.Len()
@@ -120,30 +389,35 @@ func (pool *BufferPool) Put(data interface{}) (ok bool, err error) {
 			break
 		}
 	}
+	//bufChs 被关闭后 range 直接结束
+	if !ok && err == nil {
+		err = ErrClosedBufferPool
+	}
+	pool.putFinished(ok, err)
 	return
 }
 
+// putFinished 放入结束后唤醒等待数据的 Get 调用者 并统计过载次数
+func (pool *BufferPoolOf[T]) putFinished(ok bool, err error) {
+	if ok {
+		pool.getSignal.broadcast()
+	}
+	if err == ErrBufferOverload {
+		atomic.AddUint64(&pool.overloads, 1)
+	}
+}
+
 // putData 用于向给定的缓冲器放入数据，并在必要时把缓冲器归还给池。
-func (pool *BufferPool) putData(
-	buf IBuffer, data interface{}, count *uint32, tryTimes uint32) (ok bool, err error) {
+func (pool *BufferPoolOf[T]) putData(
+	buf IBufferOf[T], data T, count *uint32, tryTimes uint32) (ok bool, err error) {
 	if pool.Closed() {
 		return false, ErrClosedBufferPool
 	}
 
-	defer func() {
-		pool.rwlock.RLock()
-		if pool.Closed() {
-			buf.Close()
-			atomic.AddUint32(&pool.poolSize, ^uint32(0))
-		} else {
-			pool.bufChs <- buf
-		}
-		pool.rwlock.RUnlock()
-	}()
+	defer pool.releasePutBuffer(buf)
 
 	if ok, err = buf.Put(data); ok {
-		atomic.AddUint64(&pool.total, 1)
-		atomic.AddUint64(&pool.putSize, 1)
+		pool.addPut(1)
 		return
 	}
 	*count++
@@ -155,75 +429,398 @@ func (pool *BufferPool) putData(
 				pool.rwlock.Unlock()
 				return
 			}
-			newBuf, _ := NewBuffer(pool.bufferCap)
-			ok, err = newBuf.Put(data)
+			newBuf, e := pool.newBuffer()
+			if e != nil {
+				pool.rwlock.Unlock()
+				return false, e
+			}
+			if ok, err = newBuf.Put(data); ok {
+				pool.addPut(1)
+			}
 			pool.bufChs <- newBuf
-			atomic.AddUint32(&pool.poolSize, 1)
-			atomic.AddUint64(&pool.total, 1)
-			atomic.AddUint64(&pool.putSize, 1)
-			atomic.AddUint32(&pool.newBufferCount, 1)
+			pool.growSpare()
 		}
 		pool.rwlock.Unlock()
 	}
 	return
 }
 
-func (pool *BufferPool) Get() (data interface{}, err error) {
+// Get 阻塞地获取数据 直到成功或者缓冲池关闭
+func (pool *BufferPoolOf[T]) Get() (data T, err error) {
+	return pool.GetContext(context.Background())
+}
+
+// GetTimeout 阻塞地获取数据 最多等待timeout
+func (pool *BufferPoolOf[T]) GetTimeout(timeout time.Duration) (data T, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return pool.GetContext(ctx)
+}
+
+// GetContext 阻塞地获取数据 缓冲池为空时等待Put放入数据
+// ctx结束时返回ctx.Err() 缓冲池关闭时返回ErrClosedBufferPool
+func (pool *BufferPoolOf[T]) GetContext(ctx context.Context) (data T, err error) {
+	err = pool.waitGet(ctx, nil, nil, func() (bool, error) {
+		data, err = pool.TryGet()
+		return err != ErrBufferEmpty, err
+	})
+	return
+}
+
+// TryGet 非阻塞地获取数据 缓冲池为空时返回ErrBufferEmpty
+// 使用了溢出日志时 内存中的数据不足会先把溢出日志中的数据放回内存
+func (pool *BufferPoolOf[T]) TryGet() (data T, err error) {
+	data, err = pool.tryGet()
+	if pool.spill != nil && pool.refillFromSpill(err) && err == ErrBufferEmpty {
+		data, err = pool.tryGet()
+	}
+	return
+}
+
+// tryGet 非阻塞地从内存的缓冲器中获取数据
+func (pool *BufferPoolOf[T]) tryGet() (data T, err error) {
 	if pool.Closed() {
-		return false, ErrClosedBufferPool
+		return data, ErrClosedBufferPool
+	}
+	if pool.ordered || pool.priority {
+		if pool.ordered {
+			data, err = pool.getOrdered()
+		} else {
+			data, err = pool.getPriority()
+		}
+		if err == nil {
+			pool.putSignal.broadcast()
+		}
+		return
 	}
 
 	var count uint32
 	var tryTimes uint32 = pool.Len()
+	var got bool
 	for buf := range pool.bufChs {
 		data, err = pool.getData(buf, &count, tryTimes)
-		if err == nil || count > tryTimes {
+		if err == nil {
+			got = true
 			break
 		}
+		if count > tryTimes {
+			break
+		}
+	}
+	//bufChs 被关闭后 range 直接结束
+	if !got && err == nil {
+		err = ErrClosedBufferPool
+	}
+	if got {
+		pool.putSignal.broadcast()
 	}
 	return
 }
 
 // getData 用于从给定的缓冲器获取数据，并在必要时把缓冲器归还给池。
-func (pool *BufferPool) getData(
-	buf IBuffer, count *uint32, tryTimes uint32) (data interface{}, err error) {
+func (pool *BufferPoolOf[T]) getData(
+	buf IBufferOf[T], count *uint32, tryTimes uint32) (data T, err error) {
 	if pool.Closed() {
 		buf.Close()
-		return false, ErrClosedBufferPool
+		return data, ErrClosedBufferPool
 	}
 
 	defer func() {
-		//必须加上写锁 有可能pool.Len读取有问题 导致pool.bufChs一直为空 Get|Putt for buf := range pool.bufChs一直堵塞
+		pool.releaseGetBuffer(buf, *count > tryTimes)
+	}()
+
+	data, err = buf.Get()
+	if err == nil {
+		pool.addGet(data)
+		return
+	}
+	*count++
+	return
+}
+
+// PutBatch 非阻塞地批量放入数据 每个缓冲器只取还一次 尽量减少通道交接和加锁的次数
+// 设置了字节数上限时 只放入不超过上限的前面部分数据
+func (pool *BufferPoolOf[T]) PutBatch(items []T) (n int, err error) {
+	return pool.putItems(items, pool.spilling())
+}
+
+// putItems 批量放入数据 full为true时按缓冲池已满处理
+func (pool *BufferPoolOf[T]) putItems(items []T, full bool) (n int, err error) {
+	if pool.Closed() {
+		return 0, ErrClosedBufferPool
+	}
+	if len(items) == 0 {
+		return 0, nil
+	}
+	if !pool.beginPut() {
+		return 0, ErrDrainingBufferPool
+	}
+	defer pool.endPut()
+	if full {
+		pool.putFinished(false, ErrBufferOverload)
+		return 0, ErrBufferOverload
+	}
+	fit := pool.reserveBatch(items)
+	if fit == 0 {
+		pool.putFinished(false, ErrBufferOverload)
+		return 0, ErrBufferOverload
+	}
+	n, err = pool.putBatch(items[:fit])
+	pool.releaseBytes(pool.sizeOf(items[n:fit]...))
+	if err == nil && fit < len(items) {
+		err = ErrBufferOverload
+	}
+	return
+}
+
+// putBatch 非阻塞地把数据批量放入缓冲器
+func (pool *BufferPoolOf[T]) putBatch(items []T) (n int, err error) {
+	if pool.ordered {
+		n, err = pool.putBatchOrdered(items)
+		pool.putFinished(n > 0, err)
+		return
+	}
+
+	var count uint32
+	var tryTimes uint32 = pool.Len()
+	for buf := range pool.bufChs {
+		var put int
+		put, err = pool.putBatchData(buf, items[n:], &count, tryTimes)
+		n += put
+		if n == len(items) || err == ErrClosedBufferPool || count > tryTimes {
+			break
+		}
+	}
+	//bufChs 被关闭后 range 直接结束
+	if n < len(items) && err == nil {
+		err = ErrClosedBufferPool
+	}
+	pool.putFinished(n > 0, err)
+	return
+}
+
+// putBatchData 用于向给定的缓冲器批量放入数据 缓冲器都满时按需创建新的缓冲器。
+func (pool *BufferPoolOf[T]) putBatchData(
+	buf IBufferOf[T], items []T, count *uint32, tryTimes uint32) (n int, err error) {
+	if pool.Closed() {
+		return 0, ErrClosedBufferPool
+	}
+
+	defer pool.releasePutBuffer(buf)
+
+	n, err = buf.PutBatch(items)
+	if n > 0 {
+		pool.addPut(uint64(n))
+	}
+	if err == nil {
+		return
+	}
+	*count++
+
+	if *count > tryTimes {
 		pool.rwlock.Lock()
-		if pool.Closed() {
-			buf.Close()
-			atomic.AddUint32(&pool.poolSize, ^uint32(0))
-			pool.rwlock.Unlock()
-			return
+		for n < len(items) && pool.Len() < pool.Cap() && !pool.Closed() {
+			newBuf, e := pool.newBuffer()
+			if e != nil {
+				err = e
+				break
+			}
+			put, _ := newBuf.PutBatch(items[n:])
+			pool.bufChs <- newBuf
+			n += put
+			pool.addPut(uint64(put))
+		}
+		if !pool.Closed() {
+			pool.growSpare()
+		}
+		pool.rwlock.Unlock()
+		if n == len(items) {
+			err = nil
 		}
+	}
+	return
+}
 
-		if *count > tryTimes && buf.Len() == 0 && pool.Len() > 1 {
-			buf.Close()
-			//time.Sleep(time.Second * 10)
-			//glog.Errorf("2 Count=%d, tryTimes=%d Closed", *count, tryTimes)
-			atomic.AddUint32(&pool.poolSize, ^uint32(0))
+// GetBatch 非阻塞地批量获取最多max个数据 每个缓冲器只取还一次
+func (pool *BufferPoolOf[T]) GetBatch(max int) (items []T, err error) {
+	if items, err = pool.getBatch(max); pool.spill != nil && pool.refillFromSpill(err) && len(items) < max {
+		var more []T
+		if more, err = pool.getBatch(max - len(items)); len(items) > 0 {
+			items, err = append(items, more...), nil
 		} else {
-			pool.bufChs <- buf
+			items = more
 		}
-		pool.rwlock.Unlock()
-	}()
+	}
+	return
+}
 
-	data, err = buf.Get()
-	if err == nil {
-		atomic.AddUint64(&pool.total, ^uint64(0))
-		atomic.AddUint64(&pool.getSize, 1)
+// getBatch 非阻塞地从内存的缓冲器中批量获取数据
+func (pool *BufferPoolOf[T]) getBatch(max int) (items []T, err error) {
+	if pool.Closed() {
+		return nil, ErrClosedBufferPool
+	}
+	if max <= 0 {
+		return nil, nil
+	}
+	if pool.ordered {
+		if items, err = pool.getBatchOrdered(max); len(items) > 0 {
+			pool.putSignal.broadcast()
+		}
 		return
 	}
-	*count++
+	if pool.priority {
+		if items, err = pool.getBatchPriority(max); len(items) > 0 {
+			pool.putSignal.broadcast()
+		}
+		return
+	}
+
+	var count uint32
+	var tryTimes uint32 = pool.Len()
+	for buf := range pool.bufChs {
+		items, err = pool.getBatchData(buf, items, max, &count, tryTimes)
+		if len(items) == max || err == ErrClosedBufferPool || count > tryTimes {
+			break
+		}
+	}
+	if len(items) > 0 {
+		err = nil
+		pool.putSignal.broadcast()
+	} else if err == nil {
+		//bufChs 被关闭后 range 直接结束
+		err = ErrClosedBufferPool
+	}
+	return
+}
+
+// getBatchData 用于从给定的缓冲器批量获取数据 追加到items后返回。
+func (pool *BufferPoolOf[T]) getBatchData(
+	buf IBufferOf[T], items []T, max int, count *uint32, tryTimes uint32) ([]T, error) {
+	if pool.Closed() {
+		buf.Close()
+		return items, ErrClosedBufferPool
+	}
+
+	defer func() {
+		pool.releaseGetBuffer(buf, *count > tryTimes)
+	}()
+
+	got, err := buf.GetBatch(max - len(items))
+	if len(got) > 0 {
+		pool.addGet(got...)
+		items = append(items, got...)
+	}
+	if len(items) < max {
+		*count++
+	}
+	return items, err
+}
+
+// acquireBuffers 取出当前所有空闲的缓冲器 至少等到一个 缓冲池关闭时返回空
+// 其它协程正在使用的缓冲器不会等待 用完后必须逐个归还
+func (pool *BufferPoolOf[T]) acquireBuffers() []IBufferOf[T] {
+	buf, ok := <-pool.bufChs
+	if !ok {
+		return nil
+	}
+	bufs := []IBufferOf[T]{buf}
+	for uint32(len(bufs)) < pool.Len() {
+		select {
+		case buf, ok := <-pool.bufChs:
+			if !ok {
+				return bufs
+			}
+			bufs = append(bufs, buf)
+		default:
+			return bufs
+		}
+	}
+	return bufs
+}
+
+// removeIf 删除缓冲池中所有满足match的数据 返回删除的数量 removed不为nil时对每个删除的数据调用
+// 逐个取空缓冲器后放回不需要删除的数据 同一缓冲器内的顺序不变 其它协程正在使用的缓冲器本次跳过
+func (pool *BufferPoolOf[T]) removeIf(match func(data T) bool, removed func(data T)) (n uint64) {
+	if pool.Closed() {
+		return 0
+	}
+	filter := func(buf IBufferOf[T]) {
+		items, _ := buf.GetBatch(int(buf.Len()))
+		keep := items[:0]
+		for _, data := range items {
+			if !match(data) {
+				keep = append(keep, data)
+				continue
+			}
+			n++
+			pool.releaseBytes(pool.sizeOf(data))
+			if removed != nil {
+				removed(data)
+			}
+		}
+		buf.PutBatch(keep)
+	}
+
+	if pool.ordered {
+		pool.segLock.Lock()
+		for _, buf := range pool.segs {
+			filter(buf)
+		}
+		pool.segLock.Unlock()
+	} else {
+		for _, buf := range pool.acquireBuffers() {
+			filter(buf)
+			pool.releasePutBuffer(buf)
+		}
+	}
+	if n > 0 {
+		pool.checkTotal(atomic.AddUint64(&pool.total, ^(n - 1)))
+		pool.putSignal.broadcast()
+	}
 	return
 }
 
-func (pool *BufferPool) Close() bool {
+// shrinkable 判断当前是否可以回收一个空的缓冲器
+// 缓冲器数量必须多于minBuffers 并且数据总数不超过shrinkThreshold
+func (pool *BufferPoolOf[T]) shrinkable() bool {
+	return pool.Len() > pool.minBuffers && pool.Total() <= pool.shrinkThreshold
+}
+
+// releasePutBuffer 把Put用完的缓冲器归还给池 缓冲池已关闭时关闭该缓冲器。
+func (pool *BufferPoolOf[T]) releasePutBuffer(buf IBufferOf[T]) {
+	pool.rwlock.RLock()
+	if pool.Closed() {
+		buf.Close()
+		pool.removeBuffer()
+	} else {
+		pool.bufChs <- buf
+	}
+	pool.rwlock.RUnlock()
+}
+
+// releaseGetBuffer 把Get用完的缓冲器归还给池
+// idle 表示本轮所有缓冲器都已取空 此时多余的空缓冲器会被回收。
+func (pool *BufferPoolOf[T]) releaseGetBuffer(buf IBufferOf[T], idle bool) {
+	//必须加上写锁 有可能pool.Len读取有问题 导致pool.bufChs一直为空 Get|Putt for buf := range pool.bufChs一直堵塞
+	pool.rwlock.Lock()
+	if pool.Closed() {
+		buf.Close()
+		pool.removeBuffer()
+		pool.rwlock.Unlock()
+		return
+	}
+
+	if pool.stale(buf) || idle && buf.Len() == 0 && pool.shrinkable() {
+		if newBuf := pool.retireBuffer(buf); newBuf != nil {
+			pool.bufChs <- newBuf
+		}
+	} else {
+		pool.bufChs <- buf
+	}
+	pool.rwlock.Unlock()
+}
+
+func (pool *BufferPoolOf[T]) Close() bool {
 	pool.rwlock.Lock()
 	if !atomic.CompareAndSwapUint32(&pool.closed, 0, 1) {
 		pool.rwlock.Unlock()
@@ -231,18 +828,29 @@ func (pool *BufferPool) Close() bool {
 	}
 	close(pool.bufChs)
 	pool.closeBufChans()
+	pool.closeOrdered()
+	close(pool.done)
 	pool.rwlock.Unlock()
+	//溢出日志放回数据时会先锁住溢出日志再加缓冲池的锁 所以在释放写锁之后关闭
+	if pool.spill != nil {
+		pool.spill.Close()
+	}
 	return true
 }
 
-func (pool *BufferPool) closeBufChans() {
+// Done 返回缓冲池关闭时被关闭的通道
+func (pool *BufferPoolOf[T]) Done() <-chan struct{} {
+	return pool.done
+}
+
+func (pool *BufferPoolOf[T]) closeBufChans() {
 	for buf := range pool.bufChs {
 		buf.Close()
 	}
 }
 
 //Closed  0-未关闭；1-已关闭
-func (pool *BufferPool) Closed() bool {
+func (pool *BufferPoolOf[T]) Closed() bool {
 	if atomic.LoadUint32(&pool.closed) == 0 {
 		return false
 	}
@@ -0,0 +1,45 @@
+package buffer
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+)
+
+// ICodec 数据编解码接口 用于把缓冲池中的数据写入磁盘或者从磁盘读出
+type ICodec[T any] interface {
+	// Encode 把数据编码为字节
+	Encode(data T) ([]byte, error)
+	// Decode 把字节解码为数据
+	Decode(b []byte) (T, error)
+}
+
+// GobCodec 使用encoding/gob编解码
+// T为interface{}时 存放的具体类型需要事先用gob.Register注册
+type GobCodec[T any] struct{}
+
+func (GobCodec[T]) Encode(data T) ([]byte, error) {
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(&data); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (GobCodec[T]) Decode(b []byte) (data T, err error) {
+	err = gob.NewDecoder(bytes.NewReader(b)).Decode(&data)
+	return
+}
+
+// JSONCodec 使用encoding/json编解码
+// T为interface{}时 解码得到的是json的通用类型(map[string]interface{} float64等)
+type JSONCodec[T any] struct{}
+
+func (JSONCodec[T]) Encode(data T) ([]byte, error) {
+	return json.Marshal(data)
+}
+
+func (JSONCodec[T]) Decode(b []byte) (data T, err error) {
+	err = json.Unmarshal(b, &data)
+	return
+}
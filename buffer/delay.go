@@ -0,0 +1,295 @@
+package buffer
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DelayQueue 存放interface{}数据的延时队列
+type DelayQueue = DelayQueueOf[interface{}]
+
+// DelayQueueOf 延时队列 实现IPoolOf接口
+// PutAt PutAfter 放入的数据先存放在按到期时间排序的最小堆中 到期后由调度协程放入缓冲池
+// Get 只能取到已经到期的数据 Put 等方法放入的数据立即可见
+// 到期时间相同的数据按放入的顺序可见
+type DelayQueueOf[T any] struct {
+	// pool 代表存放已到期数据的缓冲池
+	pool *BufferPoolOf[T]
+	// lock 保护delayed和seq
+	lock sync.Mutex
+	// delayed 代表还没有到期的数据
+	delayed delayHeap[T]
+	// seq 代表放入的序号 用于到期时间相同的数据保持顺序
+	seq uint64
+	// wake 用于通知调度协程最早的到期时间有变化
+	wake chan struct{}
+	// done 在延时队列关闭时被关闭 用于结束调度协程
+	done chan struct{}
+	// dropped 代表到期后放入缓冲池失败被丢弃的数据数量
+	dropped uint64
+}
+
+// delayItem 延时队列中还没有到期的数据
+type delayItem[T any] struct {
+	at   time.Time
+	seq  uint64
+	data T
+}
+
+// delayHeap 按到期时间排序的最小堆 实现heap.Interface
+type delayHeap[T any] []delayItem[T]
+
+func (h delayHeap[T]) Len() int { return len(h) }
+
+func (h delayHeap[T]) Less(i, j int) bool {
+	if h[i].at.Equal(h[j].at) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].at.Before(h[j].at)
+}
+
+func (h delayHeap[T]) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *delayHeap[T]) Push(x interface{}) { *h = append(*h, x.(delayItem[T])) }
+
+func (h *delayHeap[T]) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = delayItem[T]{}
+	*h = old[:len(old)-1]
+	return item
+}
+
+// NewDelayQueue 用于创建一个延时队列 参数含义同NewPool
+func NewDelayQueue(poolCap uint32, bufferCap uint32) (*DelayQueue, error) {
+	return NewDelayQueueOf[interface{}](poolCap, bufferCap)
+}
+
+// NewDelayQueueOf 用于创建一个存放T类型数据的延时队列
+// poolCap bufferCap opts 用于创建存放已到期数据的缓冲池 含义同NewPoolWithOptions
+// 缓冲池已满时按缓冲池的溢出策略处理 默认策略下调度协程阻塞 直到有空间为止
+// 期间到期的数据留在堆中 计入Delayed 延后可见 其它策略下放入失败的数据被丢弃 计入Dropped
+func NewDelayQueueOf[T any](poolCap uint32, bufferCap uint32, opts ...PoolOption[T]) (*DelayQueueOf[T], error) {
+	pool, err := NewPoolWithOptions[T](poolCap, bufferCap, opts...)
+	if err != nil {
+		return nil, err
+	}
+	queue := &DelayQueueOf[T]{
+		pool: pool,
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+	go queue.schedule()
+	return queue, nil
+}
+
+var delayFmtMsg = "cap(%d) len(%d) bufCap(%d) total(%d) delayed(%d)"
+
+func (queue *DelayQueueOf[T]) String() string {
+	return fmt.Sprintf(delayFmtMsg, queue.Cap(), queue.Len(), queue.BufferCap(), queue.Total(), queue.Delayed())
+}
+
+// PutAt 放入数据 数据在at时刻之后才能被 Get 取到 at已过去时立即可见
+// 延时的数据不占用缓冲池的空间 本方法不会阻塞
+func (queue *DelayQueueOf[T]) PutAt(data T, at time.Time) (ok bool, err error) {
+	if queue.Closed() {
+		return false, ErrClosedBufferPool
+	}
+	queue.lock.Lock()
+	queue.seq++
+	heap.Push(&queue.delayed, delayItem[T]{at: at, seq: queue.seq, data: data})
+	first := queue.delayed[0].seq == queue.seq
+	queue.lock.Unlock()
+
+	//新数据成为最早到期的数据时 通知调度协程重新设置定时器
+	if first {
+		select {
+		case queue.wake <- struct{}{}:
+		default:
+		}
+	}
+	return true, nil
+}
+
+// PutAfter 放入数据 数据在delay之后才能被 Get 取到
+func (queue *DelayQueueOf[T]) PutAfter(data T, delay time.Duration) (ok bool, err error) {
+	return queue.PutAt(data, time.Now().Add(delay))
+}
+
+// Delayed 获取还没有放入缓冲池的数据数量 包括已到期但缓冲池已满还在等待放入的数据
+func (queue *DelayQueueOf[T]) Delayed() int {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	return queue.delayed.Len()
+}
+
+// Dropped 获取到期后放入缓冲池失败被丢弃的数据数量 例如缓冲池的溢出策略为OverflowDropNewest时
+func (queue *DelayQueueOf[T]) Dropped() uint64 {
+	return atomic.LoadUint64(&queue.dropped)
+}
+
+// schedule 调度协程 把到期的数据按到期顺序放入缓冲池 直到延时队列关闭或者缓冲池开始关闭
+// 数据放入缓冲池之后才从堆中移除 其它放入错误只丢弃这一个数据
+func (queue *DelayQueueOf[T]) schedule() {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		item, next, due, ok := queue.peekDue(time.Now())
+		if due {
+			switch _, err := queue.pool.Put(item.data); err {
+			case nil:
+			case ErrClosedBufferPool, ErrDrainingBufferPool:
+				return
+			default:
+				atomic.AddUint64(&queue.dropped, 1)
+			}
+			queue.remove(item.seq)
+			continue
+		}
+
+		var wait <-chan time.Time
+		if ok {
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(time.Until(next))
+			wait = timer.C
+		}
+		select {
+		case <-wait:
+		case <-queue.wake:
+		case <-queue.done:
+			return
+		}
+	}
+}
+
+// peekDue 查看最早到期的数据 在now之前到期时due为true 否则返回它的到期时间
+// 堆为空时ok为false
+func (queue *DelayQueueOf[T]) peekDue(now time.Time) (item delayItem[T], next time.Time, due, ok bool) {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	if queue.delayed.Len() == 0 {
+		return item, next, false, false
+	}
+	if item = queue.delayed[0]; item.at.After(now) {
+		return item, item.at, false, true
+	}
+	return item, next, true, true
+}
+
+// remove 从堆中移除序号为seq的数据 放入缓冲池期间有更早到期的数据放入时它可能已不在堆顶
+func (queue *DelayQueueOf[T]) remove(seq uint64) {
+	queue.lock.Lock()
+	defer queue.lock.Unlock()
+	for i := range queue.delayed {
+		if queue.delayed[i].seq == seq {
+			heap.Remove(&queue.delayed, i)
+			return
+		}
+	}
+}
+
+func (queue *DelayQueueOf[T]) Cap() uint32 {
+	return queue.pool.Cap()
+}
+
+func (queue *DelayQueueOf[T]) Len() uint32 {
+	return queue.pool.Len()
+}
+
+func (queue *DelayQueueOf[T]) BufferCap() uint32 {
+	return queue.pool.BufferCap()
+}
+
+// Total 获取已经到期可以被 Get 取到的数据总数 不包括Delayed
+func (queue *DelayQueueOf[T]) Total() uint64 {
+	return queue.pool.Total()
+}
+
+// Put 阻塞地放入立即可见的数据
+func (queue *DelayQueueOf[T]) Put(data T) (ok bool, err error) {
+	return queue.pool.Put(data)
+}
+
+func (queue *DelayQueueOf[T]) TryPut(data T) (ok bool, err error) {
+	return queue.pool.TryPut(data)
+}
+
+func (queue *DelayQueueOf[T]) PutContext(ctx context.Context, data T) (ok bool, err error) {
+	return queue.pool.PutContext(ctx, data)
+}
+
+func (queue *DelayQueueOf[T]) PutTimeout(data T, timeout time.Duration) (ok bool, err error) {
+	return queue.pool.PutTimeout(data, timeout)
+}
+
+func (queue *DelayQueueOf[T]) PutBatch(items []T) (n int, err error) {
+	return queue.pool.PutBatch(items)
+}
+
+// Get 阻塞地获取已到期的数据 直到有数据或者延时队列关闭
+func (queue *DelayQueueOf[T]) Get() (data T, err error) {
+	return queue.pool.Get()
+}
+
+func (queue *DelayQueueOf[T]) TryGet() (data T, err error) {
+	return queue.pool.TryGet()
+}
+
+func (queue *DelayQueueOf[T]) GetContext(ctx context.Context) (data T, err error) {
+	return queue.pool.GetContext(ctx)
+}
+
+func (queue *DelayQueueOf[T]) GetTimeout(timeout time.Duration) (data T, err error) {
+	return queue.pool.GetTimeout(timeout)
+}
+
+func (queue *DelayQueueOf[T]) GetBatch(max int) (items []T, err error) {
+	return queue.pool.GetBatch(max)
+}
+
+// Peek 查看下一个已到期的数据但不取出 还没有到期的数据不在其中
+func (queue *DelayQueueOf[T]) Peek() (data T, err error) {
+	return queue.pool.Peek()
+}
+
+func (queue *DelayQueueOf[T]) PeekN(n int) (items []T, err error) {
+	return queue.pool.PeekN(n)
+}
+
+func (queue *DelayQueueOf[T]) Range(fn func(data T) bool) {
+	queue.pool.Range(fn)
+}
+
+func (queue *DelayQueueOf[T]) Stats() PoolStats {
+	return queue.pool.Stats()
+}
+
+// Close 关闭延时队列 还没有到期的数据被丢弃
+func (queue *DelayQueueOf[T]) Close() bool {
+	if !queue.pool.Close() {
+		return false
+	}
+	close(queue.done)
+	queue.lock.Lock()
+	queue.delayed = nil
+	queue.lock.Unlock()
+	return true
+}
+
+func (queue *DelayQueueOf[T]) Closed() bool {
+	return queue.pool.Closed()
+}
+
+// Done 返回缓冲池关闭时被关闭的通道
+func (queue *DelayQueueOf[T]) Done() <-chan struct{} {
+	return queue.pool.Done()
+}
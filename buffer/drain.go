@@ -0,0 +1,112 @@
+package buffer
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// ErrDrainingBufferPool 是表示缓冲池正在排空 不再接受放入的错误的变量。
+var ErrDrainingBufferPool = errors.New("pool is draining")
+
+// Drain 排空并关闭缓冲池
+// 先停止接受 Put 正在阻塞的 Put 返回ErrDrainingBufferPool 消费者可以继续 Get
+// 等到数据总数为0或者ctx结束后关闭缓冲池 返回还没有被取走的数据 以便持久化而不是丢失
+// ctx结束时err为ctx.Err() 溢出日志中的数据仍保存在磁盘上 不在返回值中
+// 排空期间不再写入溢出日志 也不再把溢出日志中的数据放回内存
+func (pool *BufferPoolOf[T]) Drain(ctx context.Context) (items []T, err error) {
+	if pool.Closed() {
+		return nil, ErrClosedBufferPool
+	}
+	pool.stopPuts()
+	err = pool.waitEmpty(ctx)
+	items = pool.takeAll()
+	pool.Close()
+	return items, err
+}
+
+// CloseWithTimeout 排空并关闭缓冲池 最多等待timeout 见Drain
+func (pool *BufferPoolOf[T]) CloseWithTimeout(timeout time.Duration) (items []T, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return pool.Drain(ctx)
+}
+
+// Draining 用于判断缓冲池是否正在排空
+func (pool *BufferPoolOf[T]) Draining() bool {
+	return atomic.LoadUint32(&pool.draining) == 1
+}
+
+// beginPut 登记一次放入 正在排空时返回false 返回true时必须调用 endPut
+func (pool *BufferPoolOf[T]) beginPut() bool {
+	atomic.AddInt32(&pool.putting, 1)
+	if pool.Draining() {
+		atomic.AddInt32(&pool.putting, -1)
+		return false
+	}
+	return true
+}
+
+// endPut 结束一次放入
+func (pool *BufferPoolOf[T]) endPut() {
+	atomic.AddInt32(&pool.putting, -1)
+}
+
+// stopPuts 停止接受放入 唤醒阻塞的 Put 并等待正在进行的放入结束
+func (pool *BufferPoolOf[T]) stopPuts() {
+	atomic.StoreUint32(&pool.draining, 1)
+	pool.putSignal.broadcast()
+	for atomic.LoadInt32(&pool.putting) > 0 {
+		runtime.Gosched()
+	}
+}
+
+// waitEmpty 等待数据总数为0 ctx结束时返回ctx.Err()
+func (pool *BufferPoolOf[T]) waitEmpty(ctx context.Context) error {
+	//Get 取出数据后会唤醒putSignal上的等待者 等待的时间不计入putWait
+	return pool.putSignal.until(ctx, pool.done, ErrClosedBufferPool, nil, nil, nil, func() (bool, error) {
+		return pool.Total() == 0, nil
+	})
+}
+
+// takeAll 取出缓冲池中剩余的所有数据
+func (pool *BufferPoolOf[T]) takeAll() (items []T) {
+	for {
+		got, err := pool.getBatch(int(pool.Total()) + 1)
+		if err != nil {
+			return
+		}
+		items = append(items, got...)
+	}
+}
+
+// Drain 排空并关闭所有的子缓冲池 见BufferPoolOf.Drain
+func (pool *ShardedPoolOf[T]) Drain(ctx context.Context) (items []T, err error) {
+	if pool.Closed() {
+		return nil, ErrClosedBufferPool
+	}
+	//先停止所有子缓冲池的放入 数据总数只会减少 再依次等待
+	for _, shard := range pool.shards {
+		shard.stopPuts()
+	}
+	pool.putSignal.broadcast()
+	for _, shard := range pool.shards {
+		if err = shard.waitEmpty(ctx); err != nil {
+			break
+		}
+	}
+	for _, shard := range pool.shards {
+		items = append(items, shard.takeAll()...)
+	}
+	pool.Close()
+	return items, err
+}
+
+// CloseWithTimeout 排空并关闭所有的子缓冲池 最多等待timeout 见Drain
+func (pool *ShardedPoolOf[T]) CloseWithTimeout(timeout time.Duration) (items []T, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return pool.Drain(ctx)
+}
@@ -0,0 +1,383 @@
+package buffer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EnvelopeOf 信封 缓冲池在放入时给数据打上的编号 放入时间和自定义头部
+type EnvelopeOf[T any] struct {
+	// ID 放入成功时分配的编号 从1开始连续递增 放入失败不占用编号
+	ID uint64
+	// EnqueuedAt 放入成功的时间 带有单调时钟读数 不包含生产者等待的时间
+	EnqueuedAt time.Time
+	// Headers 放入时传入的自定义头部 可以为nil
+	Headers map[string]string
+	// Value 数据本身
+	Value T
+}
+
+// Envelope 存放interface{}数据的信封
+type Envelope = EnvelopeOf[interface{}]
+
+// Age 获取信封从放入到now经过的时间
+func (env *EnvelopeOf[T]) Age(now time.Time) time.Duration {
+	return now.Sub(env.EnqueuedAt)
+}
+
+// DefaultLatencyBuckets 默认的排队延迟直方图区间上界
+var DefaultLatencyBuckets = []time.Duration{
+	100 * time.Microsecond, 500 * time.Microsecond,
+	time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 5 * time.Second, 10 * time.Second, time.Minute,
+}
+
+// LatencyStats 排队延迟直方图的快照
+type LatencyStats struct {
+	// Bounds 各区间的上界 从小到大
+	Bounds []time.Duration
+	// Counts 延迟不超过对应上界的累计数量 比Bounds多一项 最后一项为总数 对应+Inf
+	Counts []uint64
+	// Sum 所有延迟之和
+	Sum time.Duration
+}
+
+// Count 获取统计的数据数量
+func (s LatencyStats) Count() uint64 {
+	if len(s.Counts) == 0 {
+		return 0
+	}
+	return s.Counts[len(s.Counts)-1]
+}
+
+// Mean 获取平均延迟 没有数据时返回0
+func (s LatencyStats) Mean() time.Duration {
+	if count := s.Count(); count > 0 {
+		return s.Sum / time.Duration(count)
+	}
+	return 0
+}
+
+// Quantile 估算q分位(0 < q <= 1)的延迟 返回该分位所在区间的上界
+// 落在最后一个上界之外时返回最后一个上界 没有数据时返回0
+func (s LatencyStats) Quantile(q float64) time.Duration {
+	count := s.Count()
+	if count == 0 || len(s.Bounds) == 0 {
+		return 0
+	}
+	rank := uint64(q * float64(count))
+	if rank == 0 {
+		rank = 1
+	}
+	for i, bound := range s.Bounds {
+		if s.Counts[i] >= rank {
+			return bound
+		}
+	}
+	return s.Bounds[len(s.Bounds)-1]
+}
+
+// latencyHistogram 排队延迟直方图 各项分别原子更新
+type latencyHistogram struct {
+	// bounds 代表各区间的上界 从小到大
+	bounds []time.Duration
+	// counts 代表落在各区间的数量 不累计 最后一项对应+Inf
+	counts []uint64
+	// sum 代表所有延迟之和 单位纳秒
+	sum int64
+}
+
+// newLatencyHistogram 用区间上界创建直方图 bounds为空时使用DefaultLatencyBuckets
+func newLatencyHistogram(bounds []time.Duration) *latencyHistogram {
+	if len(bounds) == 0 {
+		bounds = DefaultLatencyBuckets
+	}
+	bounds = append([]time.Duration(nil), bounds...)
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+	return &latencyHistogram{bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+// observe 记录一个延迟
+func (h *latencyHistogram) observe(d time.Duration) {
+	i := sort.Search(len(h.bounds), func(i int) bool { return d <= h.bounds[i] })
+	atomic.AddUint64(&h.counts[i], 1)
+	atomic.AddInt64(&h.sum, int64(d))
+}
+
+// snapshot 获取直方图的快照
+func (h *latencyHistogram) snapshot() LatencyStats {
+	stats := LatencyStats{
+		Bounds: append([]time.Duration(nil), h.bounds...),
+		Counts: make([]uint64, len(h.counts)),
+		Sum:    time.Duration(atomic.LoadInt64(&h.sum)),
+	}
+	var total uint64
+	for i := range h.counts {
+		total += atomic.LoadUint64(&h.counts[i])
+		stats.Counts[i] = total
+	}
+	return stats
+}
+
+// ILatencyProvider 可以提供排队延迟直方图的缓冲池 StatsHandler 会额外输出直方图
+type ILatencyProvider interface {
+	Latency() LatencyStats
+}
+
+// EnvelopePool 存放interface{}数据的信封缓冲池
+type EnvelopePool = EnvelopePoolOf[interface{}]
+
+// EnvelopePoolOf 信封缓冲池 实现IPoolOf接口
+// 放入的数据被装进信封 打上连续递增的编号 放入时间和自定义头部
+// 编号和放入时间在放入成功的那一刻才打上 编号的顺序就是放入缓冲池的顺序
+// GetEnvelope 等方法取出完整的信封 IPoolOf的 Get 等方法只取出数据
+// 每次取出时用放入时间统计排队延迟 见Latency
+type EnvelopePoolOf[T any] struct {
+	// pool 代表实际存放信封的缓冲池
+	pool *BufferPoolOf[EnvelopeOf[T]]
+	// seq 代表最近分配的编号
+	seq uint64
+	// sealLock 保护编号的分配和信封的放入 保证只有放入成功的信封占用编号
+	sealLock sync.Mutex
+	// latency 代表排队延迟直方图
+	latency *latencyHistogram
+}
+
+// NewEnvelopePool 用于创建一个存放interface{}数据的信封缓冲池 参数含义同NewPool 使用默认的直方图区间
+func NewEnvelopePool(poolCap uint32, bufferCap uint32) (*EnvelopePool, error) {
+	pool, err := NewPoolOf[EnvelopeOf[interface{}]](poolCap, bufferCap)
+	if err != nil {
+		return nil, err
+	}
+	return NewEnvelopePoolOf(pool), nil
+}
+
+// NewEnvelopePoolOf 用于在pool之上创建信封缓冲池 pool由信封缓冲池独占 关闭信封缓冲池时一同关闭
+// buckets为排队延迟直方图的区间上界 为空时使用DefaultLatencyBuckets
+func NewEnvelopePoolOf[T any](pool *BufferPoolOf[EnvelopeOf[T]], buckets ...time.Duration) *EnvelopePoolOf[T] {
+	return &EnvelopePoolOf[T]{
+		pool:    pool,
+		latency: newLatencyHistogram(buckets),
+	}
+}
+
+var envelopeFmtMsg = "cap(%d) len(%d) bufCap(%d) total(%d) lastID(%d)"
+
+func (pool *EnvelopePoolOf[T]) String() string {
+	return fmt.Sprintf(envelopeFmtMsg, pool.Cap(), pool.Len(), pool.BufferCap(), pool.Total(), atomic.LoadUint64(&pool.seq))
+}
+
+// seal 把数据装进信封 headers被复制 之后修改headers不影响信封 编号和放入时间由tryPut打上
+func (pool *EnvelopePoolOf[T]) seal(data T, headers map[string]string) EnvelopeOf[T] {
+	env := EnvelopeOf[T]{Value: data}
+	if len(headers) > 0 {
+		env.Headers = make(map[string]string, len(headers))
+		for k, v := range headers {
+			env.Headers[k] = v
+		}
+	}
+	return env
+}
+
+// tryPut 在sealLock中给信封打上编号和放入时间后非阻塞地放入 放入成功后编号才被占用
+// overflow为true时缓冲池已满按溢出策略处理 handled为false表示需要等待Get腾出空间
+func (pool *EnvelopePoolOf[T]) tryPut(env EnvelopeOf[T], overflow bool) (ok, handled bool, err error) {
+	pool.sealLock.Lock()
+	defer pool.sealLock.Unlock()
+	env.ID, env.EnqueuedAt = pool.seq+1, time.Now()
+	if ok, err = pool.pool.TryPut(env); err != ErrBufferOverload || !overflow {
+		handled = true
+	} else {
+		ok, handled, err = pool.pool.putOverflow(env)
+	}
+	if ok {
+		atomic.StoreUint64(&pool.seq, env.ID)
+	}
+	return
+}
+
+// put 放入信封 同 BufferPoolOf.PutContext 一样等待缓冲池的通知 每次尝试时重新打上编号和放入时间
+func (pool *EnvelopePoolOf[T]) put(ctx context.Context, env EnvelopeOf[T]) (ok bool, err error) {
+	err = pool.pool.waitPut(ctx, nil, nil, func() (handled bool, err error) {
+		ok, handled, err = pool.tryPut(env, true)
+		return handled, err
+	})
+	return
+}
+
+// open 统计信封的排队延迟
+func (pool *EnvelopePoolOf[T]) open(envs ...EnvelopeOf[T]) {
+	now := time.Now()
+	for i := range envs {
+		pool.latency.observe(envs[i].Age(now))
+	}
+}
+
+// Latency 获取排队延迟直方图的快照 只统计被取出的信封
+func (pool *EnvelopePoolOf[T]) Latency() LatencyStats {
+	return pool.latency.snapshot()
+}
+
+func (pool *EnvelopePoolOf[T]) Cap() uint32 {
+	return pool.pool.Cap()
+}
+
+func (pool *EnvelopePoolOf[T]) Len() uint32 {
+	return pool.pool.Len()
+}
+
+func (pool *EnvelopePoolOf[T]) BufferCap() uint32 {
+	return pool.pool.BufferCap()
+}
+
+func (pool *EnvelopePoolOf[T]) Total() uint64 {
+	return pool.pool.Total()
+}
+
+// PutWithHeaders 阻塞地放入带自定义头部的数据
+// 编号和放入时间在放入成功时打上 等待的时间不计入排队延迟 并发放入时编号的顺序是放入的顺序
+func (pool *EnvelopePoolOf[T]) PutWithHeaders(data T, headers map[string]string) (ok bool, err error) {
+	return pool.put(context.Background(), pool.seal(data, headers))
+}
+
+// PutWithHeadersContext 阻塞地放入带自定义头部的数据 ctx 结束时返回ctx.Err()
+func (pool *EnvelopePoolOf[T]) PutWithHeadersContext(ctx context.Context, data T,
+	headers map[string]string) (ok bool, err error) {
+	return pool.put(ctx, pool.seal(data, headers))
+}
+
+func (pool *EnvelopePoolOf[T]) Put(data T) (ok bool, err error) {
+	return pool.put(context.Background(), pool.seal(data, nil))
+}
+
+func (pool *EnvelopePoolOf[T]) TryPut(data T) (ok bool, err error) {
+	ok, _, err = pool.tryPut(pool.seal(data, nil), false)
+	return
+}
+
+func (pool *EnvelopePoolOf[T]) PutContext(ctx context.Context, data T) (ok bool, err error) {
+	return pool.put(ctx, pool.seal(data, nil))
+}
+
+func (pool *EnvelopePoolOf[T]) PutTimeout(data T, timeout time.Duration) (ok bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return pool.put(ctx, pool.seal(data, nil))
+}
+
+func (pool *EnvelopePoolOf[T]) PutBatch(items []T) (n int, err error) {
+	envs := make([]EnvelopeOf[T], len(items))
+	pool.sealLock.Lock()
+	defer pool.sealLock.Unlock()
+	now := time.Now()
+	for i, data := range items {
+		envs[i] = EnvelopeOf[T]{ID: pool.seq + uint64(i) + 1, EnqueuedAt: now, Value: data}
+	}
+	//放入的总是前n个 只有它们占用编号
+	n, err = pool.pool.PutBatch(envs)
+	atomic.AddUint64(&pool.seq, uint64(n))
+	return
+}
+
+// GetEnvelope 阻塞地获取一个信封
+func (pool *EnvelopePoolOf[T]) GetEnvelope() (env EnvelopeOf[T], err error) {
+	return pool.GetEnvelopeContext(context.Background())
+}
+
+// GetEnvelopeContext 阻塞地获取一个信封 ctx 结束时返回ctx.Err()
+func (pool *EnvelopePoolOf[T]) GetEnvelopeContext(ctx context.Context) (env EnvelopeOf[T], err error) {
+	if env, err = pool.pool.GetContext(ctx); err == nil {
+		pool.open(env)
+	}
+	return
+}
+
+// TryGetEnvelope 非阻塞地获取一个信封 没有数据时返回ErrBufferEmpty
+func (pool *EnvelopePoolOf[T]) TryGetEnvelope() (env EnvelopeOf[T], err error) {
+	if env, err = pool.pool.TryGet(); err == nil {
+		pool.open(env)
+	}
+	return
+}
+
+// GetEnvelopeBatch 非阻塞地批量获取最多max个信封
+func (pool *EnvelopePoolOf[T]) GetEnvelopeBatch(max int) (envs []EnvelopeOf[T], err error) {
+	if envs, err = pool.pool.GetBatch(max); len(envs) > 0 {
+		pool.open(envs...)
+	}
+	return
+}
+
+func (pool *EnvelopePoolOf[T]) Get() (data T, err error) {
+	return pool.GetContext(context.Background())
+}
+
+func (pool *EnvelopePoolOf[T]) GetContext(ctx context.Context) (data T, err error) {
+	env, err := pool.GetEnvelopeContext(ctx)
+	return env.Value, err
+}
+
+func (pool *EnvelopePoolOf[T]) GetTimeout(timeout time.Duration) (data T, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return pool.GetContext(ctx)
+}
+
+func (pool *EnvelopePoolOf[T]) TryGet() (data T, err error) {
+	env, err := pool.TryGetEnvelope()
+	return env.Value, err
+}
+
+func (pool *EnvelopePoolOf[T]) GetBatch(max int) (items []T, err error) {
+	envs, err := pool.GetEnvelopeBatch(max)
+	for i := range envs {
+		items = append(items, envs[i].Value)
+	}
+	return
+}
+
+func (pool *EnvelopePoolOf[T]) Peek() (data T, err error) {
+	env, err := pool.pool.Peek()
+	return env.Value, err
+}
+
+func (pool *EnvelopePoolOf[T]) PeekN(n int) (items []T, err error) {
+	envs, err := pool.pool.PeekN(n)
+	for i := range envs {
+		items = append(items, envs[i].Value)
+	}
+	return
+}
+
+func (pool *EnvelopePoolOf[T]) Range(fn func(data T) bool) {
+	pool.pool.Range(func(env EnvelopeOf[T]) bool {
+		return fn(env.Value)
+	})
+}
+
+// RangeEnvelopes 依次查看缓冲池中的所有信封 fn返回false时停止 一致性同BufferPoolOf.Range
+func (pool *EnvelopePoolOf[T]) RangeEnvelopes(fn func(env EnvelopeOf[T]) bool) {
+	pool.pool.Range(fn)
+}
+
+func (pool *EnvelopePoolOf[T]) Stats() PoolStats {
+	return pool.pool.Stats()
+}
+
+func (pool *EnvelopePoolOf[T]) Close() bool {
+	return pool.pool.Close()
+}
+
+func (pool *EnvelopePoolOf[T]) Closed() bool {
+	return pool.pool.Closed()
+}
+
+// Done 返回缓冲池关闭时被关闭的通道
+func (pool *EnvelopePoolOf[T]) Done() <-chan struct{} {
+	return pool.pool.Done()
+}
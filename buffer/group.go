@@ -0,0 +1,337 @@
+package buffer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrLeftGroup 是表示消费者已离开消费组的错误的变量。
+var ErrLeftGroup = errors.New("member has left the group")
+
+// GroupTopic 存放interface{}数据的消费组主题
+type GroupTopic = GroupTopicOf[interface{}]
+
+// GroupMember 存放interface{}数据的消费组成员
+type GroupMember = GroupMemberOf[interface{}]
+
+// GroupTopicOf 消费组主题
+// 每个消费组有自己的缓冲池 Publish 把数据放入所有消费组的缓冲池 每个消费组都收到每个数据一次
+// 同一个消费组的成员从该组的缓冲池竞争获取 每个数据只交给组内的一个成员
+// 消费组在第一个成员加入时创建 最后一个成员离开后保留缓冲池 重新加入后从离开的位置继续 DeleteGroup 删除
+// 没有成员的消费组不会阻塞 Publish 缓冲池已满时按溢出策略处理 默认策略下丢弃数据 计入该组的Dropped
+type GroupTopicOf[T any] struct {
+	// poolCap bufferCap opts 代表创建消费组缓冲池的参数 含义同NewPoolWithOptions
+	poolCap   uint32
+	bufferCap uint32
+	opts      []PoolOption[T]
+	// lock 保护groups
+	lock sync.RWMutex
+	// groups 代表所有消费组 按组名索引
+	groups map[string]*consumerGroup[T]
+	// closed 代表主题的关闭状态：0-未关闭；1-已关闭。
+	closed uint32
+	// published 代表发布的数据数量
+	published uint64
+}
+
+// consumerGroup 消费组
+type consumerGroup[T any] struct {
+	// name 代表组名
+	name string
+	// pool 代表存放该组还没有消费的数据的缓冲池
+	pool *BufferPoolOf[T]
+	// members 代表当前成员的数量
+	members int32
+	// dropped 代表没有成员时缓冲池已满被丢弃的数据数量
+	dropped uint64
+}
+
+// GroupMemberOf 消费组成员
+type GroupMemberOf[T any] struct {
+	// group 代表所在的消费组
+	group *consumerGroup[T]
+	// ctx 在成员离开时被取消 用于唤醒阻塞的 Get
+	ctx    context.Context
+	cancel context.CancelFunc
+	// left 代表是否已离开：0-未离开；1-已离开。
+	left uint32
+}
+
+// GroupStats 消费组的统计快照
+type GroupStats struct {
+	// Name 组名
+	Name string
+	// Members 当前成员的数量
+	Members int
+	// Lag 该组还没有消费的数据数量
+	Lag uint64
+	// Delivered 该组已经消费的数据数量
+	Delivered uint64
+	// Dropped 该组没有成员时缓冲池已满被丢弃的数据数量
+	Dropped uint64
+	// Pool 该组缓冲池的统计
+	Pool PoolStats
+}
+
+// NewGroupTopic 用于创建一个消费组主题 参数含义同NewPool
+func NewGroupTopic(poolCap uint32, bufferCap uint32) (*GroupTopic, error) {
+	return NewGroupTopicOf[interface{}](poolCap, bufferCap)
+}
+
+// NewGroupTopicOf 用于创建一个存放T类型数据的消费组主题
+// poolCap bufferCap opts 用于创建每个消费组的缓冲池 含义同NewPoolWithOptions opts有误时返回错误
+// 消费组不能共用一个溢出日志 opts中有WithSpill时返回ErrSharedSpill
+// 消费组缓冲池已满时 Publish 按缓冲池的溢出策略处理 默认阻塞
+func NewGroupTopicOf[T any](poolCap uint32, bufferCap uint32, opts ...PoolOption[T]) (*GroupTopicOf[T], error) {
+	if poolCap == 0 || bufferCap == 0 {
+		errMsg := fmt.Sprintf("invalid params cannot eq 0 poolCap(%d) bufferCap(%d)", poolCap, bufferCap)
+		return nil, errors.New(errMsg)
+	}
+	if err := checkSharedOptions(opts); err != nil {
+		return nil, err
+	}
+	return &GroupTopicOf[T]{
+		poolCap:   poolCap,
+		bufferCap: bufferCap,
+		opts:      opts,
+		groups:    make(map[string]*consumerGroup[T]),
+	}, nil
+}
+
+var groupTopicFmtMsg = "groups(%d) published(%d)"
+
+func (topic *GroupTopicOf[T]) String() string {
+	topic.lock.RLock()
+	defer topic.lock.RUnlock()
+	return fmt.Sprintf(groupTopicFmtMsg, len(topic.groups), topic.Published())
+}
+
+// Join 加入消费组name 消费组不存在时创建 新建的消费组只能收到之后发布的数据
+func (topic *GroupTopicOf[T]) Join(name string) (*GroupMemberOf[T], error) {
+	topic.lock.Lock()
+	defer topic.lock.Unlock()
+	if topic.Closed() {
+		return nil, ErrClosedTopic
+	}
+	group, ok := topic.groups[name]
+	if !ok {
+		pool, err := NewPoolWithOptions[T](topic.poolCap, topic.bufferCap, topic.opts...)
+		if err != nil {
+			return nil, err
+		}
+		group = &consumerGroup[T]{name: name, pool: pool}
+		topic.groups[name] = group
+	}
+	atomic.AddInt32(&group.members, 1)
+	member := &GroupMemberOf[T]{group: group}
+	member.ctx, member.cancel = context.WithCancel(context.Background())
+	return member, nil
+}
+
+// DeleteGroup 删除消费组name 关闭它的缓冲池 组内成员的 Get 返回ErrClosedBufferPool
+// 消费组不存在时返回false
+func (topic *GroupTopicOf[T]) DeleteGroup(name string) bool {
+	topic.lock.Lock()
+	group, ok := topic.groups[name]
+	delete(topic.groups, name)
+	topic.lock.Unlock()
+	if ok {
+		group.pool.Close()
+	}
+	return ok
+}
+
+// Groups 获取所有消费组的统计 按组名排序
+func (topic *GroupTopicOf[T]) Groups() []GroupStats {
+	topic.lock.RLock()
+	stats := make([]GroupStats, 0, len(topic.groups))
+	for _, group := range topic.groups {
+		stats = append(stats, group.stats())
+	}
+	topic.lock.RUnlock()
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Name < stats[j].Name
+	})
+	return stats
+}
+
+// Group 获取消费组name的统计 消费组不存在时ok为false
+func (topic *GroupTopicOf[T]) Group(name string) (stats GroupStats, ok bool) {
+	topic.lock.RLock()
+	group, ok := topic.groups[name]
+	topic.lock.RUnlock()
+	if !ok {
+		return stats, false
+	}
+	return group.stats(), true
+}
+
+// Lag 获取消费组name还没有消费的数据数量 消费组不存在时返回0
+func (topic *GroupTopicOf[T]) Lag(name string) uint64 {
+	stats, _ := topic.Group(name)
+	return stats.Lag
+}
+
+// Published 获取发布的数据数量
+func (topic *GroupTopicOf[T]) Published() uint64 {
+	return atomic.LoadUint64(&topic.published)
+}
+
+// Publish 把数据放入所有消费组的缓冲池 返回收到数据的消费组数量
+func (topic *GroupTopicOf[T]) Publish(data T) (n int, err error) {
+	return topic.PublishContext(context.Background(), data)
+}
+
+// PublishContext 把数据放入所有消费组的缓冲池 返回收到数据的消费组数量
+// 有成员的消费组缓冲池已满时按溢出策略处理 默认等待 没有成员的消费组不等待
+// ctx 结束时返回ctx.Err() 此时已经收到数据的消费组不会撤回
+func (topic *GroupTopicOf[T]) PublishContext(ctx context.Context, data T) (n int, err error) {
+	//先复制消费组 阻塞等待时不能持有锁
+	topic.lock.RLock()
+	if topic.Closed() {
+		topic.lock.RUnlock()
+		return 0, ErrClosedTopic
+	}
+	groups := make([]*consumerGroup[T], 0, len(topic.groups))
+	for _, group := range topic.groups {
+		groups = append(groups, group)
+	}
+	topic.lock.RUnlock()
+
+	atomic.AddUint64(&topic.published, 1)
+	for _, group := range groups {
+		//消费组被删除时跳过
+		ok, err := group.put(ctx, data)
+		if err != nil && ctx.Err() != nil {
+			return n, ctx.Err()
+		}
+		if ok {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// put 把数据放入消费组的缓冲池 同 BufferPoolOf.PutContext 一样等待缓冲池的通知
+// 没有成员时不等待 等待期间最后一个成员离开时也不再等待 默认策略下放不下的数据丢弃
+func (group *consumerGroup[T]) put(ctx context.Context, data T) (ok bool, err error) {
+	pool := group.pool
+	err = pool.waitPut(ctx, nil, nil, func() (bool, error) {
+		if ok, err = pool.TryPut(data); err != ErrBufferOverload {
+			return true, err
+		}
+		var handled bool
+		if ok, handled, err = pool.putOverflow(data); handled {
+			return true, err
+		}
+		if atomic.LoadInt32(&group.members) == 0 {
+			atomic.AddUint64(&group.dropped, 1)
+			return true, ErrBufferOverload
+		}
+		return false, nil
+	})
+	return
+}
+
+// Close 关闭主题和所有消费组的缓冲池 若主题之前已关闭则返回false
+func (topic *GroupTopicOf[T]) Close() bool {
+	topic.lock.Lock()
+	if !atomic.CompareAndSwapUint32(&topic.closed, 0, 1) {
+		topic.lock.Unlock()
+		return false
+	}
+	groups := topic.groups
+	topic.groups = make(map[string]*consumerGroup[T])
+	topic.lock.Unlock()
+	for _, group := range groups {
+		group.pool.Close()
+	}
+	return true
+}
+
+// Closed 用于判断主题是否已关闭
+func (topic *GroupTopicOf[T]) Closed() bool {
+	return atomic.LoadUint32(&topic.closed) == 1
+}
+
+// stats 获取消费组的统计快照
+func (group *consumerGroup[T]) stats() GroupStats {
+	pool := group.pool.Stats()
+	return GroupStats{
+		Name:      group.name,
+		Members:   int(atomic.LoadInt32(&group.members)),
+		Lag:       pool.Total,
+		Delivered: pool.Gets,
+		Dropped:   atomic.LoadUint64(&group.dropped),
+		Pool:      pool,
+	}
+}
+
+// Group 获取所在消费组的组名
+func (member *GroupMemberOf[T]) Group() string {
+	return member.group.name
+}
+
+// Get 阻塞地获取数据 直到有数据 离开消费组或者消费组被删除
+func (member *GroupMemberOf[T]) Get() (data T, err error) {
+	return member.GetContext(context.Background())
+}
+
+// GetTimeout 阻塞地获取数据 最多等待timeout
+func (member *GroupMemberOf[T]) GetTimeout(timeout time.Duration) (data T, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return member.GetContext(ctx)
+}
+
+// GetContext 阻塞地获取数据 ctx 结束时返回ctx.Err() 离开消费组时返回ErrLeftGroup
+// 同 BufferPoolOf.GetContext 一样等待缓冲池的通知 同时等待ctx和成员离开
+func (member *GroupMemberOf[T]) GetContext(ctx context.Context) (data T, err error) {
+	err = member.group.pool.waitGet(ctx, member.ctx.Done(), ErrLeftGroup, func() (bool, error) {
+		if member.Left() {
+			return true, ErrLeftGroup
+		}
+		data, err = member.group.pool.TryGet()
+		return err != ErrBufferEmpty, err
+	})
+	return
+}
+
+// TryGet 非阻塞地获取数据 没有数据时返回ErrBufferEmpty
+func (member *GroupMemberOf[T]) TryGet() (data T, err error) {
+	if member.Left() {
+		return data, ErrLeftGroup
+	}
+	return member.group.pool.TryGet()
+}
+
+// GetBatch 非阻塞地批量获取最多max个数据
+func (member *GroupMemberOf[T]) GetBatch(max int) (items []T, err error) {
+	if member.Left() {
+		return nil, ErrLeftGroup
+	}
+	return member.group.pool.GetBatch(max)
+}
+
+// Leave 离开消费组 唤醒阻塞的 Get 消费组的缓冲池保留 已经离开时返回false
+func (member *GroupMemberOf[T]) Leave() bool {
+	if !atomic.CompareAndSwapUint32(&member.left, 0, 1) {
+		return false
+	}
+	//最后一个成员离开时唤醒等待该组的 Publish
+	if atomic.AddInt32(&member.group.members, -1) == 0 {
+		member.group.pool.putSignal.broadcast()
+	}
+	member.cancel()
+	return true
+}
+
+// Left 用于判断是否已离开消费组
+func (member *GroupMemberOf[T]) Left() bool {
+	return atomic.LoadUint32(&member.left) == 1
+}
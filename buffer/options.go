@@ -0,0 +1,103 @@
+package buffer
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrSharedSpill 是表示溢出日志不能被多个缓冲池共用的错误的变量。
+var ErrSharedSpill = errors.New("spill log cannot be shared by several pools")
+
+// BufferFactory 缓冲器工厂函数 参数size为缓冲池的bufferCap
+// 用于在缓冲池中使用NewBufferOf之外的IBufferOf实现
+type BufferFactory[T any] func(size uint32) (IBufferOf[T], error)
+
+// PoolOption 缓冲池的可选配置 用于NewPoolWithOptions
+type PoolOption[T any] func(pool *BufferPoolOf[T]) error
+
+// WithBufferFactory 设置创建缓冲器的工厂函数 默认为NewBufferOf
+func WithBufferFactory[T any](factory BufferFactory[T]) PoolOption[T] {
+	return func(pool *BufferPoolOf[T]) error {
+		if factory == nil {
+			return errors.New("buffer factory is nil")
+		}
+		pool.factory = factory
+		return nil
+	}
+}
+
+// WithMinBuffers 设置缓冲器的最少数量 回收空缓冲器时不会低于该值 默认为1
+func WithMinBuffers[T any](n uint32) PoolOption[T] {
+	return func(pool *BufferPoolOf[T]) error {
+		if n == 0 {
+			return fmt.Errorf("invalid params minBuffers(%d) cannot eq 0", n)
+		}
+		pool.minBuffers = n
+		if pool.initBuffers < n {
+			pool.initBuffers = n
+		}
+		return nil
+	}
+}
+
+// WithInitBuffers 设置创建缓冲池时预先创建的缓冲器数量 默认为1 不能少于minBuffers
+func WithInitBuffers[T any](n uint32) PoolOption[T] {
+	return func(pool *BufferPoolOf[T]) error {
+		if n == 0 {
+			return fmt.Errorf("invalid params initBuffers(%d) cannot eq 0", n)
+		}
+		pool.initBuffers = n
+		return nil
+	}
+}
+
+// WithGrowStep 设置缓冲器都已写满时一次新建的缓冲器数量 默认为1
+// 先进先出模式下只能写链尾 忽略该设置
+func WithGrowStep[T any](n uint32) PoolOption[T] {
+	return func(pool *BufferPoolOf[T]) error {
+		if n == 0 {
+			return fmt.Errorf("invalid params growStep(%d) cannot eq 0", n)
+		}
+		pool.growStep = n
+		return nil
+	}
+}
+
+// WithShrinkThreshold 设置回收空缓冲器的阈值 只有数据总数Total不超过threshold时才回收
+// 默认不限制 即只要有空的缓冲器就回收
+func WithShrinkThreshold[T any](threshold uint64) PoolOption[T] {
+	return func(pool *BufferPoolOf[T]) error {
+		pool.shrinkThreshold = threshold
+		return nil
+	}
+}
+
+// WithOrdered 使用严格先进先出模式 见NewOrderedPool
+func WithOrdered[T any]() PoolOption[T] {
+	return func(pool *BufferPoolOf[T]) error {
+		pool.ordered = true
+		return nil
+	}
+}
+
+// checkSharedOptions 检查opts能否用于创建多个缓冲池 在一个空的缓冲池上试用opts
+// WithSpill的溢出日志只能属于一个缓冲池 共用时数据会串到其它缓冲池 关闭一个缓冲池也会关闭溢出日志
+func checkSharedOptions[T any](opts []PoolOption[T]) error {
+	probe := &BufferPoolOf[T]{}
+	for _, opt := range opts {
+		if err := opt(probe); err != nil {
+			return err
+		}
+	}
+	if probe.spill != nil {
+		return ErrSharedSpill
+	}
+	return nil
+}
+
+// WithOverflowPolicy 设置缓冲池已满时 Put 的处理策略 见SetOverflowPolicy
+func WithOverflowPolicy[T any](policy OverflowPolicy, handler OverflowHandler[T]) PoolOption[T] {
+	return func(pool *BufferPoolOf[T]) error {
+		return pool.SetOverflowPolicy(policy, handler)
+	}
+}
@@ -0,0 +1,187 @@
+package buffer
+
+// NewOrderedPool 用于创建一个严格先进先出的数据缓冲池 参数含义同NewPool
+// 多个缓冲器首尾相连成链 Put 只写入链尾的缓冲器 写满后在链尾追加新的缓冲器
+// Get 只从链头的缓冲器读取 读空后回收链头 从而保证全局的先进先出顺序
+func NewOrderedPool(poolCap uint32, bufferCap uint32) (IPool, error) {
+	pool, err := NewOrderedPoolOf[interface{}](poolCap, bufferCap)
+	if err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
+
+// NewOrderedPoolOf 用于创建一个存放T类型数据的严格先进先出缓冲池 参数含义同NewPool
+func NewOrderedPoolOf[T any](poolCap uint32, bufferCap uint32) (*BufferPoolOf[T], error) {
+	return NewPoolWithOptions[T](poolCap, bufferCap, WithOrdered[T]())
+}
+
+// Ordered 用于判断缓冲池是否为严格先进先出模式
+func (pool *BufferPoolOf[T]) Ordered() bool {
+	return pool.ordered
+}
+
+// putOrdered 向链尾的缓冲器放入数据 链尾已满时追加新的缓冲器
+func (pool *BufferPoolOf[T]) putOrdered(data T) (ok bool, err error) {
+	pool.segLock.Lock()
+	defer pool.segLock.Unlock()
+	if pool.Closed() {
+		return false, ErrClosedBufferPool
+	}
+
+	if ok, err = pool.segs[len(pool.segs)-1].Put(data); !ok {
+		var newBuf IBufferOf[T]
+		if newBuf, err = pool.growOrdered(); newBuf == nil {
+			return
+		}
+		ok, err = newBuf.Put(data)
+	}
+	if ok {
+		pool.addPut(1)
+	}
+	return
+}
+
+// putBatchOrdered 向链尾批量放入数据 链尾已满时追加新的缓冲器
+func (pool *BufferPoolOf[T]) putBatchOrdered(items []T) (n int, err error) {
+	pool.segLock.Lock()
+	defer pool.segLock.Unlock()
+	if pool.Closed() {
+		return 0, ErrClosedBufferPool
+	}
+
+	tail := pool.segs[len(pool.segs)-1]
+	for {
+		var put int
+		put, err = tail.PutBatch(items[n:])
+		n += put
+		if err == nil {
+			break
+		}
+		var e error
+		if tail, e = pool.growOrdered(); tail == nil {
+			if e != nil {
+				err = e
+			}
+			break
+		}
+	}
+	if n > 0 {
+		pool.addPut(uint64(n))
+	}
+	return
+}
+
+// growOrdered 在链尾追加一个新的缓冲器 缓冲器数量已达上限时返回nil和ErrBufferOverload
+// 先进先出模式下只能写链尾 所以忽略增长步长 调用方必须持有segLock
+func (pool *BufferPoolOf[T]) growOrdered() (IBufferOf[T], error) {
+	if pool.Len() >= pool.Cap() {
+		return nil, ErrBufferOverload
+	}
+	newBuf, err := pool.newBuffer()
+	if err != nil {
+		return nil, err
+	}
+	pool.segs = append(pool.segs, newBuf)
+	return newBuf, nil
+}
+
+// getOrdered 从链头的缓冲器获取数据 链头读空且后面还有缓冲器时回收链头
+func (pool *BufferPoolOf[T]) getOrdered() (data T, err error) {
+	pool.segLock.Lock()
+	defer pool.segLock.Unlock()
+	if pool.Closed() {
+		return data, ErrClosedBufferPool
+	}
+
+	//空的链头可能被挪到链尾重复使用 最多遍历一遍链
+	for i := len(pool.segs); i > 0; i-- {
+		if data, err = pool.segs[0].Get(); err == nil {
+			pool.addGet(data)
+			return
+		}
+		if !pool.shrinkOrdered() {
+			return
+		}
+	}
+	return
+}
+
+// evictOrdered 淘汰链头最老的一个数据 再把后面每个缓冲器的第一个数据挪到前一个缓冲器的末尾
+// 空位因此逐个后移到链尾 让新数据可以放入 顺序不变 返回淘汰的数量
+func (pool *BufferPoolOf[T]) evictOrdered() (n int, err error) {
+	pool.segLock.Lock()
+	defer pool.segLock.Unlock()
+	if pool.Closed() {
+		return 0, ErrClosedBufferPool
+	}
+
+	data, err := pool.segs[0].Get()
+	if err != nil {
+		return 0, err
+	}
+	pool.addGet(data)
+	for i := 0; i+1 < len(pool.segs); i++ {
+		prev, next := pool.segs[i], pool.segs[i+1]
+		if prev.Len() >= prev.Cap() {
+			continue
+		}
+		if data, err = next.Get(); err != nil {
+			break
+		}
+		prev.Put(data)
+	}
+	return 1, nil
+}
+
+// getBatchOrdered 从链头开始批量获取最多max个数据
+func (pool *BufferPoolOf[T]) getBatchOrdered(max int) (items []T, err error) {
+	pool.segLock.Lock()
+	defer pool.segLock.Unlock()
+	if pool.Closed() {
+		return nil, ErrClosedBufferPool
+	}
+
+	for i := len(pool.segs); i > 0; i-- {
+		var got []T
+		got, err = pool.segs[0].GetBatch(max - len(items))
+		items = append(items, got...)
+		if len(items) == max || !pool.shrinkOrdered() {
+			break
+		}
+	}
+	if len(items) > 0 {
+		err = nil
+		pool.addGet(items...)
+	}
+	return
+}
+
+// shrinkOrdered 移走已读空的链头缓冲器 链中只剩一个缓冲器时返回false
+// 不满足回收条件时把空的链头挪到链尾重复使用 链尾之前写入的数据仍然先被读到 不影响顺序
+// 调用方必须持有segLock
+func (pool *BufferPoolOf[T]) shrinkOrdered() bool {
+	if len(pool.segs) == 1 {
+		return false
+	}
+	head := pool.segs[0]
+	pool.segs[0] = nil
+	pool.segs = pool.segs[1:]
+	if pool.stale(head) || pool.shrinkable() {
+		if newBuf := pool.retireBuffer(head); newBuf != nil {
+			pool.segs = append(pool.segs, newBuf)
+		}
+	} else {
+		pool.segs = append(pool.segs, head)
+	}
+	return true
+}
+
+// closeOrdered 关闭链上所有的缓冲器
+func (pool *BufferPoolOf[T]) closeOrdered() {
+	pool.segLock.Lock()
+	for _, buf := range pool.segs {
+		buf.Close()
+	}
+	pool.segLock.Unlock()
+}
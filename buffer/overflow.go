@@ -0,0 +1,144 @@
+package buffer
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ErrOverflowHandlerNil 溢出策略为OverflowSpill时没有设置溢出处理函数
+var ErrOverflowHandlerNil = errors.New("overflow handler is nil")
+
+// OverflowPolicy 缓冲池已满(缓冲器数量达到poolCap且都已写满)时 Put 的处理策略
+// 只作用于 Put PutContext PutTimeout  TryPut 和 PutBatch 始终直接返回ErrBufferOverload
+type OverflowPolicy int32
+
+const (
+	// OverflowBlock 阻塞生产者 直到有空间或者缓冲池关闭 默认策略
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest 丢弃新放入的数据 返回ErrBufferOverload 计入Dropped
+	OverflowDropNewest
+	// OverflowDropOldest 淘汰缓冲池中的一个老数据腾出空间 计入Evicted
+	// 先进先出模式下只能写链尾 链头读空后才能腾出空间 所以一次淘汰链头缓冲器中剩余的数据 即全局最老的一批数据
+	// 优先级模式下淘汰最低优先级中最老的数据
+	// 普通模式下缓冲器之间没有全局顺序 淘汰的是下一个轮到的缓冲器的队头 即该缓冲器中最老的数据
+	// 腾出的空间被并发的生产者抢先占用时最多重试maxEvictTries次 仍放不下返回ErrBufferOverload
+	OverflowDropOldest
+	// OverflowSpill 把新放入的数据交给溢出处理函数 计入Spilled
+	OverflowSpill
+)
+
+// maxEvictTries OverflowDropOldest策略下淘汰后重新放入的最多次数
+const maxEvictTries = 8
+
+var overflowPolicyNames = [...]string{"block", "drop-newest", "drop-oldest", "spill"}
+
+func (p OverflowPolicy) String() string {
+	if p < 0 || int(p) >= len(overflowPolicyNames) {
+		return "unknown"
+	}
+	return overflowPolicyNames[p]
+}
+
+// OverflowHandler 溢出处理函数 返回nil表示数据已被接管
+type OverflowHandler[T any] func(data T) error
+
+// overflowConfig 溢出策略和处理函数 整体替换以便并发读取
+type overflowConfig[T any] struct {
+	policy  OverflowPolicy
+	handler OverflowHandler[T]
+}
+
+// SetOverflowPolicy 设置缓冲池已满时 Put 的处理策略
+// policy为OverflowSpill时handler不能为nil 其它策略忽略handler
+func (pool *BufferPoolOf[T]) SetOverflowPolicy(policy OverflowPolicy, handler OverflowHandler[T]) error {
+	if policy == OverflowSpill && handler == nil {
+		return ErrOverflowHandlerNil
+	}
+	pool.overflow.Store(&overflowConfig[T]{policy: policy, handler: handler})
+	return nil
+}
+
+// OverflowPolicy 获取缓冲池当前的溢出策略
+func (pool *BufferPoolOf[T]) OverflowPolicy() OverflowPolicy {
+	if cfg := pool.overflow.Load(); cfg != nil {
+		return cfg.policy
+	}
+	return OverflowBlock
+}
+
+// Dropped 获取OverflowDropNewest策略下丢弃的数据数量
+func (pool *BufferPoolOf[T]) Dropped() uint64 {
+	return atomic.LoadUint64(&pool.dropped)
+}
+
+// Evicted 获取OverflowDropOldest策略下淘汰的数据数量
+func (pool *BufferPoolOf[T]) Evicted() uint64 {
+	return atomic.LoadUint64(&pool.evicted)
+}
+
+// Spilled 获取OverflowSpill策略下交给溢出处理函数的数据数量
+func (pool *BufferPoolOf[T]) Spilled() uint64 {
+	return atomic.LoadUint64(&pool.spilled)
+}
+
+// putOverflow 按溢出策略处理放不进缓冲池的数据
+// 返回handled为false表示策略为OverflowBlock 需要调用方继续等待 正在排空时返回ErrDrainingBufferPool
+func (pool *BufferPoolOf[T]) putOverflow(data T) (ok, handled bool, err error) {
+	cfg := pool.overflow.Load()
+	if cfg == nil || cfg.policy == OverflowBlock {
+		return false, false, ErrBufferOverload
+	}
+	//排空时既不能淘汰数据 也不能交给溢出处理函数
+	if !pool.beginPut() {
+		return false, true, ErrDrainingBufferPool
+	}
+	defer pool.endPut()
+
+	switch cfg.policy {
+	case OverflowDropNewest:
+		atomic.AddUint64(&pool.dropped, 1)
+		return false, true, ErrBufferOverload
+	case OverflowDropOldest:
+		for i := 0; i < maxEvictTries; i++ {
+			if n, e := pool.evictOldest(); e == nil {
+				atomic.AddUint64(&pool.evicted, uint64(n))
+			} else if e == ErrClosedBufferPool {
+				return false, true, e
+			}
+			if ok, err = pool.TryPut(data); err != ErrBufferOverload {
+				return ok, true, err
+			}
+		}
+		return false, true, ErrBufferOverload
+	case OverflowSpill:
+		if err = cfg.handler(data); err != nil {
+			return false, true, err
+		}
+		atomic.AddUint64(&pool.spilled, 1)
+		return true, true, nil
+	}
+	return false, true, ErrBufferOverload
+}
+
+// evictOldest 按OverflowDropOldest的规则淘汰数据 返回淘汰的数量
+func (pool *BufferPoolOf[T]) evictOldest() (n int, err error) {
+	switch {
+	case pool.Closed():
+		return 0, ErrClosedBufferPool
+	case pool.ordered:
+		n, err = pool.evictOrdered()
+	case pool.priority:
+		if _, err = pool.evictPriority(); err == nil {
+			n = 1
+		}
+	default:
+		if _, err = pool.tryGet(); err == nil {
+			n = 1
+		}
+		return
+	}
+	if err == nil {
+		pool.putSignal.broadcast()
+	}
+	return
+}
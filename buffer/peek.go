@@ -0,0 +1,203 @@
+package buffer
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// Peek 查看下一个被 Get 的数据但不取出 见PeekN
+func (pool *BufferPoolOf[T]) Peek() (data T, err error) {
+	items, err := pool.PeekN(1)
+	if err != nil {
+		return data, err
+	}
+	return items[0], nil
+}
+
+// PeekN 按 Get 的顺序查看最多n个数据但不取出 一个都没有时返回ErrBufferEmpty
+// 先进先出模式下按全局顺序 查看时短暂持有链的锁
+// 普通模式下和 Get 一样只查看空闲的缓冲器 正在被其它协程使用的缓冲器跳过
+// 按缓冲器被取用的顺序 查看到n个数据后不再取用后面的缓冲器
+// 优先级模式下和 Get 一样等待并查看所有的缓冲器 按 Get 在缓冲器之间选择的规则合并 不模拟缓冲器之间的饿死保护
+// 并发 Get 时下一个取到的数据不一定是查看到的数据 溢出日志中的数据不在其中
+func (pool *BufferPoolOf[T]) PeekN(n int) (items []T, err error) {
+	if pool.Closed() {
+		return nil, ErrClosedBufferPool
+	}
+	if n <= 0 {
+		return nil, nil
+	}
+	if items = pool.peek(n); len(items) == 0 {
+		return nil, ErrBufferEmpty
+	}
+	return items, nil
+}
+
+// Range 按PeekN的顺序依次查看缓冲池中的所有数据 fn返回false时停止
+// 复制数据时短暂独占所有的缓冲器 得到某一时刻的一致视图
+// 先复制所有数据再调用fn 调用fn时 Put Get 照常进行 fn中可以访问本缓冲池
+func (pool *BufferPoolOf[T]) Range(fn func(data T) bool) {
+	if pool.Closed() {
+		return
+	}
+	for _, data := range pool.peekAll() {
+		if !fn(data) {
+			return
+		}
+	}
+}
+
+// peek 按 Get 的顺序复制最多n个数据 普通模式下不等待正在被使用的缓冲器
+func (pool *BufferPoolOf[T]) peek(n int) (items []T) {
+	if pool.ordered {
+		pool.segLock.Lock()
+		defer pool.segLock.Unlock()
+		return peekBuffers(pool.segs, n)
+	}
+	if pool.priority {
+		bufs := pool.lockPriority()
+		defer pool.unlockPriority(bufs, false)
+		return mergePriority(bufs, n)
+	}
+
+	//逐个取用空闲的缓冲器 至少等到一个 查看到n个数据后按取用的顺序归还
+	var bufs []IBufferOf[T]
+	defer func() { pool.releasePeekBuffers(bufs) }()
+	for len(items) < n && uint32(len(bufs)) < pool.Len() {
+		var buf IBufferOf[T]
+		var ok bool
+		if len(bufs) == 0 {
+			buf, ok = <-pool.bufChs
+		} else {
+			select {
+			case buf, ok = <-pool.bufChs:
+			default:
+				return
+			}
+		}
+		if !ok {
+			return
+		}
+		bufs = append(bufs, buf)
+		got, _ := buf.PeekN(n - len(items))
+		items = append(items, got...)
+	}
+	return
+}
+
+// peekAll 独占所有的缓冲器 按 Get 的顺序复制其中所有的数据
+func (pool *BufferPoolOf[T]) peekAll() []T {
+	//同时有两个调用者各自独占一部分缓冲器时会互相等待 所以逐个进行
+	pool.freezeLock.Lock()
+	defer pool.freezeLock.Unlock()
+	bufs := pool.freeze()
+	defer pool.thaw(bufs)
+	if pool.priority {
+		return mergePriority(bufs, math.MaxInt)
+	}
+	return peekBuffers(bufs, math.MaxInt)
+}
+
+// releasePeekBuffers 按取用的顺序归还查看过的缓冲器
+func (pool *BufferPoolOf[T]) releasePeekBuffers(bufs []IBufferOf[T]) {
+	for _, buf := range bufs {
+		pool.releasePutBuffer(buf)
+	}
+}
+
+// peekBuffers 依次查看每个缓冲器 复制最多n个数据
+func peekBuffers[T any](bufs []IBufferOf[T], n int) (items []T) {
+	for _, buf := range bufs {
+		if len(items) >= n {
+			break
+		}
+		got, _ := buf.PeekN(n - len(items))
+		items = append(items, got...)
+	}
+	return
+}
+
+// mergePriority 按 pickPriority 的规则合并各个缓冲器中的数据 复制最多n个数据
+// 每次从剩余数据的最高优先级最高的缓冲器中取它的下一个数据 优先级相同时取靠前的缓冲器
+func mergePriority[T any](bufs []IBufferOf[T], n int) (items []T) {
+	lists := make([][]T, len(bufs))
+	//tops[i][j] 代表缓冲器i从第j个数据开始的剩余数据中的最高优先级
+	tops := make([][]int, len(bufs))
+	for i, buf := range bufs {
+		lists[i], _ = buf.PeekN(n)
+		p, ok := buf.(*PriorityBufferOf[T])
+		tops[i] = make([]int, len(lists[i])+1)
+		tops[i][len(lists[i])] = math.MaxInt
+		for j := len(lists[i]) - 1; j >= 0; j-- {
+			level := 0
+			if ok {
+				level = p.level(lists[i][j])
+			}
+			if tops[i][j] = tops[i][j+1]; level < tops[i][j] {
+				tops[i][j] = level
+			}
+		}
+	}
+
+	heads := make([]int, len(bufs))
+	for len(items) < n {
+		pick := -1
+		for i := range lists {
+			if heads[i] < len(lists[i]) && (pick < 0 || tops[i][heads[i]] < tops[pick][heads[pick]]) {
+				pick = i
+			}
+		}
+		if pick < 0 {
+			break
+		}
+		items = append(items, lists[pick][heads[pick]])
+		heads[pick]++
+	}
+	return
+}
+
+// Peek 查看下一个被 Get 的数据但不取出 见PeekN
+func (pool *ShardedPoolOf[T]) Peek() (data T, err error) {
+	items, err := pool.PeekN(1)
+	if err != nil {
+		return data, err
+	}
+	return items[0], nil
+}
+
+// PeekN 从下一次 Get 轮询到的子缓冲池开始 依次查看最多n个数据但不取出
+// 每个子缓冲池内是某一时刻的一致视图 子缓冲池之间不是同一时刻
+func (pool *ShardedPoolOf[T]) PeekN(n int) (items []T, err error) {
+	if pool.Closed() {
+		return nil, ErrClosedBufferPool
+	}
+	if n <= 0 {
+		return nil, nil
+	}
+
+	start := atomic.LoadUint32(&pool.getIndex) + 1
+	for i := range pool.shards {
+		shard := pool.shards[(start+uint32(i))%uint32(len(pool.shards))]
+		got, _ := shard.PeekN(n - len(items))
+		if items = append(items, got...); len(items) == n {
+			break
+		}
+	}
+	if len(items) == 0 {
+		return nil, ErrBufferEmpty
+	}
+	return items, nil
+}
+
+// Range 按PeekN的顺序依次查看所有子缓冲池中的数据 fn返回false时停止
+func (pool *ShardedPoolOf[T]) Range(fn func(data T) bool) {
+	start := atomic.LoadUint32(&pool.getIndex) + 1
+	next := true
+	for i := 0; i < len(pool.shards) && next; i++ {
+		shard := pool.shards[(start+uint32(i))%uint32(len(pool.shards))]
+		shard.Range(func(data T) bool {
+			next = fn(data)
+			return next
+		})
+	}
+}
@@ -0,0 +1,433 @@
+package buffer
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// PriorityBuffer 存放interface{}数据的优先级缓冲器
+type PriorityBuffer = PriorityBufferOf[interface{}]
+
+// PriorityBufferOf 优先级缓冲器 实现IBufferOf接口
+// 数据按priority函数分到levels个优先级 0为最高优先级 同一优先级内先进先出
+// Get 总是返回最高优先级的数据 为了防止低优先级饿死
+// 某个非空的低优先级连续被跳过starveLimit次后 下一次 Get 先返回它的数据
+type PriorityBufferOf[T any] struct {
+	// lock 保护下面所有字段
+	lock sync.Mutex
+	// size 代表缓冲器的容量 所有优先级共享
+	size uint32
+	// count 代表缓冲器中的数据数量
+	count uint32
+	// queues 代表每个优先级的先进先出队列
+	queues [][]T
+	// skipped 代表每个优先级非空时连续被跳过的次数
+	skipped []uint32
+	// priority 代表计算数据优先级的函数
+	priority func(data T) int
+	// starveLimit 代表低优先级最多连续被跳过的次数 为0时不做饿死保护
+	starveLimit uint32
+	// closed 代表缓冲器的关闭状态：0-未关闭；1-已关闭。
+	closed uint32
+}
+
+// NewPriorityBuffer 用于创建一个优先级缓冲器 参数含义同NewPriorityBufferOf
+func NewPriorityBuffer(size uint32, levels int, priority func(data interface{}) int, starveLimit uint32) (IBuffer, error) {
+	return NewPriorityBufferOf[interface{}](size, levels, priority, starveLimit)
+}
+
+// NewPriorityBufferOf 用于创建一个存放T类型数据的优先级缓冲器
+// size为缓冲器的容量 levels为优先级的数量 priority返回数据的优先级 超出[0,levels)的按最近的优先级处理
+// starveLimit为低优先级最多连续被跳过的次数 为0时严格按优先级返回
+func NewPriorityBufferOf[T any](size uint32, levels int, priority func(data T) int, starveLimit uint32) (*PriorityBufferOf[T], error) {
+	if size == 0 || levels <= 0 {
+		errMsg := fmt.Sprintf("illegal params for priority buffer: size(%d) levels(%d)", size, levels)
+		return nil, errors.New(errMsg)
+	}
+	if priority == nil {
+		return nil, errors.New("priority func is nil")
+	}
+	return &PriorityBufferOf[T]{
+		size:        size,
+		queues:      make([][]T, levels),
+		skipped:     make([]uint32, levels),
+		priority:    priority,
+		starveLimit: starveLimit,
+	}, nil
+}
+
+// PriorityBufferFactory 返回创建优先级缓冲器的工厂函数 用于WithBufferFactory
+func PriorityBufferFactory[T any](levels int, priority func(data T) int, starveLimit uint32) BufferFactory[T] {
+	return func(size uint32) (IBufferOf[T], error) {
+		return NewPriorityBufferOf[T](size, levels, priority, starveLimit)
+	}
+}
+
+func (buf *PriorityBufferOf[T]) Cap() uint32 {
+	return buf.size
+}
+
+func (buf *PriorityBufferOf[T]) Len() uint32 {
+	buf.lock.Lock()
+	defer buf.lock.Unlock()
+	return buf.count
+}
+
+// level 计算数据的优先级 超出范围的取最近的优先级
+func (buf *PriorityBufferOf[T]) level(data T) int {
+	level := buf.priority(data)
+	if level < 0 {
+		return 0
+	}
+	if level >= len(buf.queues) {
+		return len(buf.queues) - 1
+	}
+	return level
+}
+
+func (buf *PriorityBufferOf[T]) Put(data T) (ok bool, err error) {
+	level := buf.level(data)
+	buf.lock.Lock()
+	defer buf.lock.Unlock()
+	if buf.Closed() {
+		return false, ErrClosedBuffer
+	}
+	if buf.count >= buf.size {
+		return false, ErrBufferOverload
+	}
+	buf.queues[level] = append(buf.queues[level], data)
+	buf.count++
+	return true, nil
+}
+
+func (buf *PriorityBufferOf[T]) PutBatch(items []T) (n int, err error) {
+	buf.lock.Lock()
+	defer buf.lock.Unlock()
+	if buf.Closed() {
+		return 0, ErrClosedBuffer
+	}
+	for _, data := range items {
+		if buf.count >= buf.size {
+			return n, ErrBufferOverload
+		}
+		level := buf.level(data)
+		buf.queues[level] = append(buf.queues[level], data)
+		buf.count++
+		n++
+	}
+	return n, nil
+}
+
+// Get 获取最高优先级的数据 缓冲器关闭后仍可以取出剩余的数据 取完后返回ErrClosedBuffer
+func (buf *PriorityBufferOf[T]) Get() (data T, err error) {
+	buf.lock.Lock()
+	defer buf.lock.Unlock()
+	if buf.count == 0 {
+		if buf.Closed() {
+			return data, ErrClosedBuffer
+		}
+		return data, ErrBufferEmpty
+	}
+	return buf.pop(), nil
+}
+
+func (buf *PriorityBufferOf[T]) GetBatch(max int) (items []T, err error) {
+	buf.lock.Lock()
+	defer buf.lock.Unlock()
+	if buf.count == 0 {
+		if buf.Closed() {
+			return nil, ErrClosedBuffer
+		}
+		return nil, ErrBufferEmpty
+	}
+	for len(items) < max && buf.count > 0 {
+		items = append(items, buf.pop())
+	}
+	return items, nil
+}
+
+// pop 取出下一个数据 调用方必须持有lock并保证缓冲器非空
+func (buf *PriorityBufferOf[T]) pop() T {
+	level := buf.nextLevel(nil, buf.skipped)
+	var zero T
+	data := buf.queues[level][0]
+	buf.queues[level][0] = zero
+	buf.queues[level] = buf.queues[level][1:]
+	buf.count--
+	return data
+}
+
+// nextLevel 选出下一个出队的优先级并更新skipped
+// heads为每个优先级已经出队的数据数量 为nil时都为0 调用方必须持有lock并保证还有数据
+func (buf *PriorityBufferOf[T]) nextLevel(heads []int, skipped []uint32) int {
+	top := 0
+	for buf.remain(heads, top) == 0 {
+		top++
+	}
+
+	//从最低优先级往上找 连续被跳过太多次的优先级先出
+	level := top
+	if buf.starveLimit > 0 {
+		for l := len(buf.queues) - 1; l > top; l-- {
+			if buf.remain(heads, l) > 0 && skipped[l] >= buf.starveLimit {
+				level = l
+				break
+			}
+		}
+	}
+	for l := top; l < len(buf.queues); l++ {
+		if l != level && buf.remain(heads, l) > 0 {
+			skipped[l]++
+		}
+	}
+	skipped[level] = 0
+	return level
+}
+
+// remain 获取优先级level中还没有出队的数据数量
+func (buf *PriorityBufferOf[T]) remain(heads []int, level int) int {
+	if heads == nil {
+		return len(buf.queues[level])
+	}
+	return len(buf.queues[level]) - heads[level]
+}
+
+func (buf *PriorityBufferOf[T]) Peek() (data T, err error) {
+	items, err := buf.PeekN(1)
+	if err != nil {
+		return data, err
+	}
+	return items[0], nil
+}
+
+// PeekN 按 Get 的顺序查看数据 包括饿死保护的效果 不改变缓冲器的状态
+// 缓冲器关闭后仍可以查看剩余的数据
+func (buf *PriorityBufferOf[T]) PeekN(n int) (items []T, err error) {
+	buf.lock.Lock()
+	defer buf.lock.Unlock()
+	if buf.count == 0 {
+		if buf.Closed() {
+			return nil, ErrClosedBuffer
+		}
+		return nil, ErrBufferEmpty
+	}
+
+	//在副本上模拟出队
+	heads := make([]int, len(buf.queues))
+	skipped := append([]uint32(nil), buf.skipped...)
+	for len(items) < n && uint32(len(items)) < buf.count {
+		level := buf.nextLevel(heads, skipped)
+		items = append(items, buf.queues[level][heads[level]])
+		heads[level]++
+	}
+	return items, nil
+}
+
+// Range 先复制所有的数据再依次调用fn 调用fn时不持有锁 fn中可以访问本缓冲器
+func (buf *PriorityBufferOf[T]) Range(fn func(data T) bool) {
+	items, _ := buf.PeekN(int(buf.Cap()))
+	for _, data := range items {
+		if !fn(data) {
+			return
+		}
+	}
+}
+
+// bottomLevel 获取缓冲器中数据的最低优先级 缓冲器为空时ok为false
+func (buf *PriorityBufferOf[T]) bottomLevel() (level int, ok bool) {
+	buf.lock.Lock()
+	defer buf.lock.Unlock()
+	for level = len(buf.queues) - 1; level >= 0; level-- {
+		if len(buf.queues[level]) > 0 {
+			return level, true
+		}
+	}
+	return 0, false
+}
+
+// evict 取出最低优先级中最老的数据 用于OverflowDropOldest
+func (buf *PriorityBufferOf[T]) evict() (data T, err error) {
+	buf.lock.Lock()
+	defer buf.lock.Unlock()
+	for level := len(buf.queues) - 1; level >= 0; level-- {
+		if len(buf.queues[level]) > 0 {
+			var zero T
+			data = buf.queues[level][0]
+			buf.queues[level][0] = zero
+			buf.queues[level] = buf.queues[level][1:]
+			buf.count--
+			return data, nil
+		}
+	}
+	if buf.Closed() {
+		return data, ErrClosedBuffer
+	}
+	return data, ErrBufferEmpty
+}
+
+// TopLevel 获取缓冲器中数据的最高优先级 缓冲器为空时ok为false
+func (buf *PriorityBufferOf[T]) TopLevel() (level int, ok bool) {
+	buf.lock.Lock()
+	defer buf.lock.Unlock()
+	for level = range buf.queues {
+		if len(buf.queues[level]) > 0 {
+			return level, true
+		}
+	}
+	return 0, false
+}
+
+func (buf *PriorityBufferOf[T]) Close() bool {
+	return atomic.CompareAndSwapUint32(&buf.closed, 0, 1)
+}
+
+func (buf *PriorityBufferOf[T]) Closed() bool {
+	return atomic.LoadUint32(&buf.closed) == 1
+}
+
+// ITopLevel 可以查询数据最高优先级的缓冲器 优先级模式的缓冲池用它在缓冲器之间选择
+type ITopLevel interface {
+	TopLevel() (level int, ok bool)
+}
+
+// WithPriority 使用优先级模式 缓冲池中的缓冲器都是PriorityBufferOf
+// Get 等待其它协程归还正在使用的缓冲器 查看所有的缓冲器后从数据优先级最高的缓冲器中获取
+// 所以总是取到整个缓冲池中优先级最高的数据 代价是优先级模式下的 Get 逐个进行
+// starveLimit同时用于缓冲器内部和缓冲器之间的饿死保护 不能和WithOrdered同时使用
+func WithPriority[T any](levels int, priority func(data T) int, starveLimit uint32) PoolOption[T] {
+	return func(pool *BufferPoolOf[T]) error {
+		if levels <= 0 || priority == nil {
+			errMsg := fmt.Sprintf("invalid params for priority pool: levels(%d) priority(%v)", levels, priority != nil)
+			return errors.New(errMsg)
+		}
+		pool.factory = PriorityBufferFactory[T](levels, priority, starveLimit)
+		pool.priority = true
+		pool.starveLimit = starveLimit
+		return nil
+	}
+}
+
+// Priority 用于判断缓冲池是否为优先级模式
+func (pool *BufferPoolOf[T]) Priority() bool {
+	return pool.priority
+}
+
+// lockPriority 在freezeLock中取出所有的缓冲器 等待其它协程归还正在使用的缓冲器 用完后必须调用 unlockPriority
+// 同一时刻只有一个调用者 避免两个调用者各自取出一部分缓冲器后互相等待 缓冲池关闭时返回的缓冲器可能不全
+func (pool *BufferPoolOf[T]) lockPriority() []IBufferOf[T] {
+	pool.freezeLock.Lock()
+	return pool.freeze()
+}
+
+// unlockPriority 归还lockPriority取出的缓冲器 idle为true时回收多余的空缓冲器
+func (pool *BufferPoolOf[T]) unlockPriority(bufs []IBufferOf[T], idle bool) {
+	for _, buf := range bufs {
+		pool.releaseGetBuffer(buf, idle)
+	}
+	pool.freezeLock.Unlock()
+}
+
+// getPriority 取出所有的缓冲器 从数据优先级最高的缓冲器中获取一个数据
+func (pool *BufferPoolOf[T]) getPriority() (data T, err error) {
+	bufs := pool.lockPriority()
+	if len(bufs) == 0 {
+		pool.unlockPriority(bufs, false)
+		return data, ErrClosedBufferPool
+	}
+	data, err = pool.pickPriority(bufs)
+	pool.unlockPriority(bufs, err != nil)
+	return
+}
+
+// getBatchPriority 取出所有的缓冲器 按优先级依次获取最多max个数据 每个数据都在缓冲器之间重新选择
+func (pool *BufferPoolOf[T]) getBatchPriority(max int) (items []T, err error) {
+	bufs := pool.lockPriority()
+	if len(bufs) == 0 {
+		pool.unlockPriority(bufs, false)
+		return nil, ErrClosedBufferPool
+	}
+	for len(items) < max {
+		var data T
+		if data, err = pool.pickPriority(bufs); err != nil {
+			break
+		}
+		items = append(items, data)
+	}
+	if len(items) > 0 {
+		err = nil
+	}
+	pool.unlockPriority(bufs, len(items) < max)
+	return
+}
+
+// pickPriority 从数据优先级最高的缓冲器中获取数据 bufs必须是缓冲池中所有的缓冲器
+// 数据较低的缓冲器连续被跳过starveLimit次后 先从数据优先级最低的缓冲器中获取
+func (pool *BufferPoolOf[T]) pickPriority(bufs []IBufferOf[T]) (data T, err error) {
+	best, worst, bestLevel, worstLevel := -1, -1, 0, 0
+	for i, buf := range bufs {
+		level, ok := topLevel(buf)
+		if !ok {
+			continue
+		}
+		if best < 0 || level < bestLevel {
+			best, bestLevel = i, level
+		}
+		if worst < 0 || level > worstLevel {
+			worst, worstLevel = i, level
+		}
+	}
+
+	pick := best
+	if best >= 0 && worstLevel > bestLevel {
+		if skipped := atomic.AddUint32(&pool.prioSkipped, 1); pool.starveLimit > 0 && skipped > pool.starveLimit {
+			pick = worst
+			atomic.StoreUint32(&pool.prioSkipped, 0)
+		}
+	}
+
+	err = ErrBufferEmpty
+	if pick >= 0 {
+		if data, err = bufs[pick].Get(); err == nil {
+			pool.addGet(data)
+		}
+	}
+	return
+}
+
+// evictPriority 取出所有的缓冲器 淘汰数据优先级最低的缓冲器中最低优先级的最老数据
+func (pool *BufferPoolOf[T]) evictPriority() (data T, err error) {
+	bufs := pool.lockPriority()
+	if len(bufs) == 0 {
+		pool.unlockPriority(bufs, false)
+		return data, ErrClosedBufferPool
+	}
+
+	var pick *PriorityBufferOf[T]
+	pickLevel := 0
+	for _, buf := range bufs {
+		if p, ok := buf.(*PriorityBufferOf[T]); ok {
+			if level, ok := p.bottomLevel(); ok && (pick == nil || level > pickLevel) {
+				pick, pickLevel = p, level
+			}
+		}
+	}
+
+	err = ErrBufferEmpty
+	if pick != nil {
+		if data, err = pick.evict(); err == nil {
+			pool.addGet(data)
+		}
+	}
+	pool.unlockPriority(bufs, pick == nil)
+	return
+}
+
+// topLevel 查询缓冲器中数据的最高优先级 不是ITopLevel的缓冲器按是否有数据处理
+func topLevel[T any](buf IBufferOf[T]) (int, bool) {
+	if t, ok := buf.(ITopLevel); ok {
+		return t.TopLevel()
+	}
+	return 0, buf.Len() > 0
+}
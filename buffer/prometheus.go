@@ -0,0 +1,173 @@
+package buffer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// IStatsProvider 可以提供运行统计的缓冲池 IPoolOf的所有实现都满足该接口
+type IStatsProvider interface {
+	Stats() PoolStats
+}
+
+// StatsHandler 以Prometheus文本格式输出已注册缓冲池的运行统计 实现http.Handler
+// 每个缓冲池以 pool="名称" 标签区分
+type StatsHandler struct {
+	// lock 保护pools
+	lock sync.RWMutex
+	// pools 代表已注册的缓冲池 key为名称
+	pools map[string]IStatsProvider
+}
+
+// NewStatsHandler 创建一个统计输出器
+func NewStatsHandler() *StatsHandler {
+	return &StatsHandler{pools: make(map[string]IStatsProvider)}
+}
+
+// Register 以name注册一个缓冲池 名称重复时返回错误
+func (h *StatsHandler) Register(name string, pool IStatsProvider) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if _, ok := h.pools[name]; ok {
+		return fmt.Errorf("pool %q already registered", name)
+	}
+	h.pools[name] = pool
+	return nil
+}
+
+// Unregister 注销name对应的缓冲池
+func (h *StatsHandler) Unregister(name string) {
+	h.lock.Lock()
+	delete(h.pools, name)
+	h.lock.Unlock()
+}
+
+// ServeHTTP 输出Prometheus文本格式的统计数据
+func (h *StatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	h.WriteTo(w)
+}
+
+// poolMetric 一项统计指标
+type poolMetric struct {
+	name  string
+	kind  string
+	help  string
+	value func(s *PoolStats) float64
+}
+
+var poolMetrics = []poolMetric{
+	{"buffer_pool_puts_total", "counter", "Total number of items put into the pool.",
+		func(s *PoolStats) float64 { return float64(s.Puts) }},
+	{"buffer_pool_gets_total", "counter", "Total number of items taken from the pool.",
+		func(s *PoolStats) float64 { return float64(s.Gets) }},
+	{"buffer_pool_overloads_total", "counter", "Total number of puts that found the pool full.",
+		func(s *PoolStats) float64 { return float64(s.Overloads) }},
+	{"buffer_pool_dropped_total", "counter", "Total number of items dropped by the drop-newest overflow policy.",
+		func(s *PoolStats) float64 { return float64(s.Dropped) }},
+	{"buffer_pool_evicted_total", "counter", "Total number of items evicted by the drop-oldest overflow policy.",
+		func(s *PoolStats) float64 { return float64(s.Evicted) }},
+	{"buffer_pool_spilled_total", "counter", "Total number of items handed to the spill overflow handler.",
+		func(s *PoolStats) float64 { return float64(s.Spilled) }},
+	{"buffer_pool_expired_total", "counter", "Total number of items discarded after their TTL expired.",
+		func(s *PoolStats) float64 { return float64(s.Expired) }},
+	{"buffer_pool_buffers_created_total", "counter", "Total number of buffers created.",
+		func(s *PoolStats) float64 { return float64(s.BuffersCreated) }},
+	{"buffer_pool_buffers_destroyed_total", "counter", "Total number of buffers destroyed.",
+		func(s *PoolStats) float64 { return float64(s.BuffersDestroyed) }},
+	{"buffer_pool_buffers", "gauge", "Current number of buffers.",
+		func(s *PoolStats) float64 { return float64(s.Buffers) }},
+	{"buffer_pool_buffers_max", "gauge", "Maximum number of buffers.",
+		func(s *PoolStats) float64 { return float64(s.Cap) }},
+	{"buffer_pool_buffer_capacity", "gauge", "Capacity of each buffer.",
+		func(s *PoolStats) float64 { return float64(s.BufferCap) }},
+	{"buffer_pool_items", "gauge", "Current number of items in the pool.",
+		func(s *PoolStats) float64 { return float64(s.Total) }},
+	{"buffer_pool_items_high_water", "gauge", "Highest number of items ever held by the pool.",
+		func(s *PoolStats) float64 { return float64(s.HighWaterTotal) }},
+	{"buffer_pool_bytes", "gauge", "Current number of bytes held by the pool.",
+		func(s *PoolStats) float64 { return float64(s.Bytes) }},
+	{"buffer_pool_bytes_max", "gauge", "Maximum number of bytes the pool may hold, 0 if unlimited.",
+		func(s *PoolStats) float64 { return float64(s.MaxBytes) }},
+	{"buffer_pool_put_wait_seconds_total", "counter", "Total time producers spent blocked in Put.",
+		func(s *PoolStats) float64 { return s.PutWait.Seconds() }},
+	{"buffer_pool_get_wait_seconds_total", "counter", "Total time consumers spent blocked in Get.",
+		func(s *PoolStats) float64 { return s.GetWait.Seconds() }},
+}
+
+// labelEscaper 转义Prometheus标签值中的特殊字符
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// WriteTo 把统计数据以Prometheus文本格式写入w 缓冲池按名称排序
+func (h *StatsHandler) WriteTo(w io.Writer) (int64, error) {
+	h.lock.RLock()
+	names := make([]string, 0, len(h.pools))
+	for name := range h.pools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	stats := make([]PoolStats, len(names))
+	latencies := make([]*LatencyStats, len(names))
+	for i, name := range names {
+		stats[i] = h.pools[name].Stats()
+		if p, ok := h.pools[name].(ILatencyProvider); ok {
+			latency := p.Latency()
+			latencies[i] = &latency
+		}
+	}
+	h.lock.RUnlock()
+
+	cw := &countWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, m := range poolMetrics {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+		for i, name := range names {
+			fmt.Fprintf(bw, "%s{pool=\"%s\"} %v\n", m.name, labelEscaper.Replace(name), m.value(&stats[i]))
+		}
+	}
+	writeLatency(bw, names, latencies)
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// latencyMetric 排队延迟直方图的指标名
+const latencyMetric = "buffer_pool_queue_latency_seconds"
+
+// writeLatency 输出提供了排队延迟直方图的缓冲池的直方图 latencies中为nil的缓冲池跳过
+func writeLatency(w io.Writer, names []string, latencies []*LatencyStats) {
+	header := false
+	for i, latency := range latencies {
+		if latency == nil {
+			continue
+		}
+		if !header {
+			fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", latencyMetric,
+				"Time items spent in the pool between put and get.", latencyMetric)
+			header = true
+		}
+		name := labelEscaper.Replace(names[i])
+		for j, bound := range latency.Bounds {
+			fmt.Fprintf(w, "%s_bucket{pool=\"%s\",le=\"%v\"} %d\n", latencyMetric, name, bound.Seconds(), latency.Counts[j])
+		}
+		fmt.Fprintf(w, "%s_bucket{pool=\"%s\",le=\"+Inf\"} %d\n", latencyMetric, name, latency.Count())
+		fmt.Fprintf(w, "%s_sum{pool=\"%s\"} %v\n", latencyMetric, name, latency.Sum.Seconds())
+		fmt.Fprintf(w, "%s_count{pool=\"%s\"} %d\n", latencyMetric, name, latency.Count())
+	}
+}
+
+// countWriter 统计写入的字节数
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
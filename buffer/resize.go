@@ -0,0 +1,129 @@
+package buffer
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// DefaultMaxPoolCap 没有用WithMaxPoolCap设置时 SetPoolCap可以设置的最大值 创建时的poolCap更大时取poolCap
+// 存放缓冲器的通道按该值预先分配 每个位置只占一个接口值的大小 缓冲器本身仍然按需创建
+const DefaultMaxPoolCap = 1024
+
+// WithMaxPoolCap 设置SetPoolCap可以设置的最大值 不能小于poolCap 默认见DefaultMaxPoolCap
+// 存放缓冲器的通道按该值预先分配 创建后不能再扩大 需要更大的上限或者想少占内存时用它设置
+func WithMaxPoolCap[T any](n uint32) PoolOption[T] {
+	return func(pool *BufferPoolOf[T]) error {
+		if n < pool.poolCap {
+			return fmt.Errorf("invalid params maxPoolCap(%d) < poolCap(%d)", n, pool.poolCap)
+		}
+		pool.maxPoolCap = n
+		return nil
+	}
+}
+
+// SetPoolCap 运行时修改缓冲器的最大数量 取值范围为[minBuffers, maxPoolCap]
+// maxPoolCap默认为DefaultMaxPoolCap 所以NewPool NewPoolOf创建的缓冲池也可以调大 超过maxPoolCap时返回错误
+// 调大后 Put 在缓冲器都已写满时可以继续新建缓冲器
+// 调小后多出的缓冲器在读空后淘汰 不会丢失数据 也不会阻塞正在进行的 Put Get
+func (pool *BufferPoolOf[T]) SetPoolCap(n uint32) error {
+	if pool.Closed() {
+		return ErrClosedBufferPool
+	}
+	if n < pool.minBuffers {
+		errMsg := fmt.Sprintf("invalid params poolCap(%d) < minBuffers(%d)", n, pool.minBuffers)
+		return errors.New(errMsg)
+	}
+	if n > pool.maxPoolCap {
+		errMsg := fmt.Sprintf("invalid params poolCap(%d) > maxPoolCap(%d)", n, pool.maxPoolCap)
+		return errors.New(errMsg)
+	}
+	atomic.StoreUint32(&pool.poolCap, n)
+	pool.sweep()
+	pool.putSignal.broadcast()
+	return nil
+}
+
+// SetBufferCap 运行时修改缓冲器的容量 之后新建的缓冲器使用新的容量
+// 容量不同的旧缓冲器在读空后淘汰 缓冲器少于minBuffers时用新的容量补上
+func (pool *BufferPoolOf[T]) SetBufferCap(n uint32) error {
+	if n == 0 {
+		return fmt.Errorf("invalid params bufferCap(%d) cannot eq 0", n)
+	}
+	//先用工厂函数试建一个缓冲器 得到新容量下缓冲器的实际容量
+	probe, err := pool.factory(n)
+	if err != nil {
+		return err
+	}
+	probe.Close()
+
+	pool.rwlock.Lock()
+	pool.segLock.Lock()
+	if pool.Closed() {
+		pool.segLock.Unlock()
+		pool.rwlock.Unlock()
+		return ErrClosedBufferPool
+	}
+	atomic.StoreUint32(&pool.bufferCap, n)
+	atomic.StoreUint32(&pool.bufCapActual, probe.Cap())
+	pool.segLock.Unlock()
+	pool.rwlock.Unlock()
+	pool.sweep()
+	pool.putSignal.broadcast()
+	return nil
+}
+
+// stale 判断缓冲器是否因为SetPoolCap SetBufferCap需要淘汰 只淘汰空的缓冲器
+func (pool *BufferPoolOf[T]) stale(buf IBufferOf[T]) bool {
+	if buf.Len() != 0 {
+		return false
+	}
+	return pool.Len() > pool.Cap() || buf.Cap() != atomic.LoadUint32(&pool.bufCapActual)
+}
+
+// retireBuffer 关闭并回收一个空的缓冲器 缓冲器少于minBuffers时返回新建的缓冲器
+// 调用方必须持有写锁 先进先出模式下持有segLock
+func (pool *BufferPoolOf[T]) retireBuffer(buf IBufferOf[T]) IBufferOf[T] {
+	buf.Close()
+	pool.removeBuffer()
+	if pool.Len() >= pool.minBuffers {
+		return nil
+	}
+	newBuf, err := pool.newBuffer()
+	if err != nil {
+		return nil
+	}
+	return newBuf
+}
+
+// sweep 检查当前所有空闲的缓冲器 淘汰需要淘汰的空缓冲器
+// 先进先出模式下空的链头在下一次 Get 时淘汰
+func (pool *BufferPoolOf[T]) sweep() {
+	if pool.ordered {
+		return
+	}
+	for _, buf := range pool.acquireBuffers() {
+		pool.releaseGetBuffer(buf, false)
+	}
+}
+
+// SetPoolCap 修改每个子缓冲池的缓冲器最大数量 见BufferPoolOf.SetPoolCap
+func (pool *ShardedPoolOf[T]) SetPoolCap(n uint32) error {
+	for _, shard := range pool.shards {
+		if err := shard.SetPoolCap(n); err != nil {
+			return err
+		}
+	}
+	pool.putSignal.broadcast()
+	return nil
+}
+
+// SetBufferCap 修改每个子缓冲池的缓冲器容量 见BufferPoolOf.SetBufferCap
+func (pool *ShardedPoolOf[T]) SetBufferCap(n uint32) error {
+	for _, shard := range pool.shards {
+		if err := shard.SetBufferCap(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
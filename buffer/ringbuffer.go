@@ -0,0 +1,228 @@
+package buffer
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+)
+
+// cacheLinePad 用于把读写位置隔开在不同的缓存行上 避免伪共享
+type cacheLinePad [64 - 8]byte
+
+// ringPeekBit 查看数据时加在读位置上的标记 期间 Get 等待
+const ringPeekBit = 1 << 63
+
+// ringSlot 环形缓冲器的槽位
+// seq 为槽位的序号 等于写位置时可写 等于写位置+1时可读
+type ringSlot[T any] struct {
+	seq  uint64
+	data T
+}
+
+// RingBuffer 存放interface{}数据的无锁环形缓冲器
+type RingBuffer = RingBufferOf[interface{}]
+
+// RingBufferOf 无锁的多生产者多消费者环形缓冲器 实现IBufferOf接口
+// 每个槽位带有序号 生产者和消费者只通过CAS推进各自的位置 不使用锁和通道
+// 容量会向上取整为2的幂
+type RingBufferOf[T any] struct {
+	_ cacheLinePad
+	// head 代表下一个读取的位置
+	head uint64
+	_    cacheLinePad
+	// tail 代表下一个写入的位置
+	tail uint64
+	_    cacheLinePad
+	// mask 代表容量减1 用于把位置映射到槽位
+	mask uint64
+	// slots 代表存放数据的槽位
+	slots []ringSlot[T]
+	// closed 代表缓冲器的关闭状态：0-未关闭；1-已关闭。
+	closed uint32
+}
+
+// NewRingBuffer 用于创建一个无锁环形缓冲器 参数size代表缓冲器的容量 会向上取整为2的幂
+func NewRingBuffer(size uint32) (IBuffer, error) {
+	return NewRingBufferOf[interface{}](size)
+}
+
+// NewRingBufferOf 用于创建一个存放T类型数据的无锁环形缓冲器
+// 可以作为BufferFactory传给WithBufferFactory 在缓冲池中替换通道实现的缓冲器
+func NewRingBufferOf[T any](size uint32) (IBufferOf[T], error) {
+	if size == 0 || size > 1<<31 {
+		errMsg := fmt.Sprintf("illegal size for ring buffer: %d", size)
+		return nil, errors.New(errMsg)
+	}
+
+	capacity := uint64(1)
+	for capacity < uint64(size) {
+		capacity <<= 1
+	}
+	buf := &RingBufferOf[T]{
+		mask:  capacity - 1,
+		slots: make([]ringSlot[T], capacity),
+	}
+	for i := range buf.slots {
+		buf.slots[i].seq = uint64(i)
+	}
+	return buf, nil
+}
+
+func (buf *RingBufferOf[T]) Cap() uint32 {
+	return uint32(len(buf.slots))
+}
+
+func (buf *RingBufferOf[T]) Len() uint32 {
+	head := atomic.LoadUint64(&buf.head) &^ ringPeekBit
+	tail := atomic.LoadUint64(&buf.tail)
+	if tail <= head {
+		return 0
+	}
+	if n := tail - head; n < uint64(len(buf.slots)) {
+		return uint32(n)
+	}
+	return uint32(len(buf.slots))
+}
+
+func (buf *RingBufferOf[T]) Put(data T) (ok bool, err error) {
+	if buf.Closed() {
+		return false, ErrClosedBuffer
+	}
+
+	pos := atomic.LoadUint64(&buf.tail)
+	for {
+		slot := &buf.slots[pos&buf.mask]
+		seq := atomic.LoadUint64(&slot.seq)
+		switch dif := int64(seq - pos); {
+		case dif == 0:
+			if atomic.CompareAndSwapUint64(&buf.tail, pos, pos+1) {
+				slot.data = data
+				atomic.StoreUint64(&slot.seq, pos+1)
+				return true, nil
+			}
+			pos = atomic.LoadUint64(&buf.tail)
+		case dif < 0:
+			//槽位上一轮的数据还没有被取走 缓冲器已满
+			return false, ErrBufferOverload
+		default:
+			pos = atomic.LoadUint64(&buf.tail)
+		}
+	}
+}
+
+// Get 获取数据 缓冲器关闭后仍可以取出剩余的数据 取完后返回ErrClosedBuffer
+func (buf *RingBufferOf[T]) Get() (data T, err error) {
+	pos := atomic.LoadUint64(&buf.head)
+	for {
+		if pos&ringPeekBit != 0 {
+			//正在查看数据 等待查看结束
+			runtime.Gosched()
+			pos = atomic.LoadUint64(&buf.head)
+			continue
+		}
+		slot := &buf.slots[pos&buf.mask]
+		seq := atomic.LoadUint64(&slot.seq)
+		switch dif := int64(seq - (pos + 1)); {
+		case dif == 0:
+			if atomic.CompareAndSwapUint64(&buf.head, pos, pos+1) {
+				var zero T
+				data, slot.data = slot.data, zero
+				atomic.StoreUint64(&slot.seq, pos+buf.mask+1)
+				return data, nil
+			}
+			pos = atomic.LoadUint64(&buf.head)
+		case dif < 0:
+			//槽位还没有写入数据 缓冲器为空
+			if buf.Closed() {
+				return data, ErrClosedBuffer
+			}
+			return data, ErrBufferEmpty
+		default:
+			pos = atomic.LoadUint64(&buf.head)
+		}
+	}
+}
+
+func (buf *RingBufferOf[T]) PutBatch(items []T) (n int, err error) {
+	for _, data := range items {
+		if _, err = buf.Put(data); err != nil {
+			return
+		}
+		n++
+	}
+	return
+}
+
+func (buf *RingBufferOf[T]) GetBatch(max int) (items []T, err error) {
+	for len(items) < max {
+		data, e := buf.Get()
+		if e != nil {
+			if len(items) == 0 {
+				err = e
+			}
+			return
+		}
+		items = append(items, data)
+	}
+	return
+}
+
+func (buf *RingBufferOf[T]) Peek() (data T, err error) {
+	items, err := buf.PeekN(1)
+	if err != nil {
+		return data, err
+	}
+	return items[0], nil
+}
+
+// PeekN 查看数据时给读位置加上标记 期间 Get 自旋等待 Put 不受影响
+// 返回的是加标记时已经写入完成的数据 缓冲器关闭后仍可以查看剩余的数据
+func (buf *RingBufferOf[T]) PeekN(n int) (items []T, err error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	var pos uint64
+	for {
+		pos = atomic.LoadUint64(&buf.head)
+		if pos&ringPeekBit == 0 && atomic.CompareAndSwapUint64(&buf.head, pos, pos|ringPeekBit) {
+			break
+		}
+		runtime.Gosched()
+	}
+	//已写入完成的槽位在读位置推进之前不会被改写
+	for p := pos; len(items) < n && p-pos < uint64(len(buf.slots)); p++ {
+		slot := &buf.slots[p&buf.mask]
+		if atomic.LoadUint64(&slot.seq) != p+1 {
+			break
+		}
+		items = append(items, slot.data)
+	}
+	atomic.StoreUint64(&buf.head, pos)
+
+	if len(items) == 0 {
+		if buf.Closed() {
+			return nil, ErrClosedBuffer
+		}
+		return nil, ErrBufferEmpty
+	}
+	return items, nil
+}
+
+// Range 先复制所有的数据再依次调用fn 调用fn时 Put Get 照常进行
+func (buf *RingBufferOf[T]) Range(fn func(data T) bool) {
+	items, _ := buf.PeekN(len(buf.slots))
+	for _, data := range items {
+		if !fn(data) {
+			return
+		}
+	}
+}
+
+func (buf *RingBufferOf[T]) Close() bool {
+	return atomic.CompareAndSwapUint32(&buf.closed, 0, 1)
+}
+
+func (buf *RingBufferOf[T]) Closed() bool {
+	return atomic.LoadUint32(&buf.closed) == 1
+}
@@ -0,0 +1,293 @@
+package buffer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// ShardedPool 存放interface{}数据的分片缓冲池
+type ShardedPool = ShardedPoolOf[interface{}]
+
+// ShardedPoolOf 分片缓冲池 实现IPoolOf接口
+// 数据分散在多个相互独立的子缓冲池中 每个子缓冲池有自己的bufChs和读写锁
+// Put 按轮询或者指定的key选择子缓冲池 Get 从轮询的位置开始 取不到时依次从其它子缓冲池窃取
+type ShardedPoolOf[T any] struct {
+	// shards 代表子缓冲池。
+	shards []*BufferPoolOf[T]
+	// putIndex 代表轮询Put的位置。
+	putIndex uint32
+	// getIndex 代表轮询Get的位置。
+	getIndex uint32
+	// closed 代表缓冲池的关闭状态：0-未关闭；1-已关闭。
+	closed uint32
+	// putSignal 用于唤醒等待空间的 Put 调用者。
+	putSignal *signal
+	// getSignal 用于唤醒等待数据的 Get 调用者。
+	getSignal *signal
+	// done 在缓冲池关闭时被关闭 用于唤醒所有阻塞的调用者。
+	done chan struct{}
+	// putWait 代表 Put 阻塞等待的累计时间 单位纳秒
+	putWait int64
+	// getWait 代表 Get 阻塞等待的累计时间 单位纳秒
+	getWait int64
+}
+
+// NewShardedPool 用于创建一个分片缓冲池
+// 参数shards代表子缓冲池的数量 poolCap和bufferCap为每个子缓冲池的参数 含义同NewPool
+func NewShardedPool(shards uint32, poolCap uint32, bufferCap uint32) (IPool, error) {
+	pool, err := NewShardedPoolOf[interface{}](shards, poolCap, bufferCap)
+	if err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
+
+// NewShardedPoolOf 用于创建一个存放T类型数据的分片缓冲池 参数含义同NewShardedPool
+// opts 应用于每个子缓冲池 子缓冲池的溢出策略不生效 分片缓冲池已满时 Put 总是阻塞
+// 子缓冲池不能共用一个溢出日志 opts中有WithSpill时返回ErrSharedSpill
+func NewShardedPoolOf[T any](shards uint32, poolCap uint32, bufferCap uint32, opts ...PoolOption[T]) (*ShardedPoolOf[T], error) {
+	if shards == 0 {
+		errMsg := fmt.Sprintf("invalid params cannot eq 0 shards(%d)", shards)
+		return nil, errors.New(errMsg)
+	}
+	if err := checkSharedOptions(opts); err != nil {
+		return nil, err
+	}
+
+	pool := &ShardedPoolOf[T]{
+		shards:    make([]*BufferPoolOf[T], shards),
+		putSignal: newSignal(),
+		getSignal: newSignal(),
+		done:      make(chan struct{}),
+	}
+	for i := range pool.shards {
+		shard, err := NewPoolWithOptions[T](poolCap, bufferCap, opts...)
+		if err != nil {
+			for _, s := range pool.shards[:i] {
+				s.Close()
+			}
+			return nil, err
+		}
+		pool.shards[i] = shard
+	}
+	return pool, nil
+}
+
+var shardedFmtMsg = "shards(%d) cap(%d) len(%d) bufCap(%d) total(%d)"
+
+func (pool *ShardedPoolOf[T]) String() string {
+	return fmt.Sprintf(shardedFmtMsg, len(pool.shards), pool.Cap(), pool.Len(), pool.BufferCap(), pool.Total())
+}
+
+// Shards 获取子缓冲池 用于查看每个分片的状态
+func (pool *ShardedPoolOf[T]) Shards() []*BufferPoolOf[T] {
+	return pool.shards
+}
+
+// Cap 所有子缓冲池中缓冲器的最大数量之和
+func (pool *ShardedPoolOf[T]) Cap() uint32 {
+	var n uint32
+	for _, shard := range pool.shards {
+		n += shard.Cap()
+	}
+	return n
+}
+
+// Len 所有子缓冲池中缓冲器的实际数量之和
+func (pool *ShardedPoolOf[T]) Len() uint32 {
+	var n uint32
+	for _, shard := range pool.shards {
+		n += shard.Len()
+	}
+	return n
+}
+
+func (pool *ShardedPoolOf[T]) BufferCap() uint32 {
+	return pool.shards[0].BufferCap()
+}
+
+// Total 所有子缓冲池中数据的总数
+func (pool *ShardedPoolOf[T]) Total() uint64 {
+	var n uint64
+	for _, shard := range pool.shards {
+		n += shard.Total()
+	}
+	return n
+}
+
+// Put 阻塞地放入数据 直到成功或者缓冲池关闭
+func (pool *ShardedPoolOf[T]) Put(data T) (ok bool, err error) {
+	return pool.PutContext(context.Background(), data)
+}
+
+// PutTimeout 阻塞地放入数据 最多等待timeout
+func (pool *ShardedPoolOf[T]) PutTimeout(data T, timeout time.Duration) (ok bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return pool.PutContext(ctx, data)
+}
+
+// PutContext 阻塞地放入数据 所有子缓冲池都已满时等待Get腾出空间
+func (pool *ShardedPoolOf[T]) PutContext(ctx context.Context, data T) (ok bool, err error) {
+	return pool.putContext(ctx, func() (bool, error) { return pool.TryPut(data) })
+}
+
+// TryPut 非阻塞地放入数据 从轮询的子缓冲池开始 放不下时依次尝试其它子缓冲池
+func (pool *ShardedPoolOf[T]) TryPut(data T) (ok bool, err error) {
+	if pool.Closed() {
+		return false, ErrClosedBufferPool
+	}
+
+	start := atomic.AddUint32(&pool.putIndex, 1)
+	for i := range pool.shards {
+		shard := pool.shards[(start+uint32(i))%uint32(len(pool.shards))]
+		if ok, err = shard.TryPut(data); err != ErrBufferOverload {
+			break
+		}
+	}
+	if ok {
+		pool.getSignal.broadcast()
+	}
+	return
+}
+
+// PutKey 阻塞地把数据放入key对应的子缓冲池 相同key的数据总是进入同一个子缓冲池
+func (pool *ShardedPoolOf[T]) PutKey(key uint64, data T) (ok bool, err error) {
+	return pool.putContext(context.Background(), func() (bool, error) { return pool.TryPutKey(key, data) })
+}
+
+// TryPutKey 非阻塞地把数据放入key对应的子缓冲池
+func (pool *ShardedPoolOf[T]) TryPutKey(key uint64, data T) (ok bool, err error) {
+	if pool.Closed() {
+		return false, ErrClosedBufferPool
+	}
+	if ok, err = pool.shards[key%uint64(len(pool.shards))].TryPut(data); ok {
+		pool.getSignal.broadcast()
+	}
+	return
+}
+
+// putContext 反复调用tryPut 缓冲池已满时等待Get腾出空间
+func (pool *ShardedPoolOf[T]) putContext(ctx context.Context, tryPut func() (bool, error)) (ok bool, err error) {
+	err = pool.putSignal.until(ctx, pool.done, ErrClosedBufferPool, nil, nil, &pool.putWait, func() (bool, error) {
+		ok, err = tryPut()
+		return err != ErrBufferOverload, err
+	})
+	return
+}
+
+// PutBatch 非阻塞地批量放入数据 从轮询的子缓冲池开始 放不下的部分依次放入其它子缓冲池
+func (pool *ShardedPoolOf[T]) PutBatch(items []T) (n int, err error) {
+	if pool.Closed() {
+		return 0, ErrClosedBufferPool
+	}
+	if len(items) == 0 {
+		return 0, nil
+	}
+
+	start := atomic.AddUint32(&pool.putIndex, 1)
+	for i := range pool.shards {
+		shard := pool.shards[(start+uint32(i))%uint32(len(pool.shards))]
+		var put int
+		put, err = shard.PutBatch(items[n:])
+		n += put
+		if n == len(items) || err != ErrBufferOverload {
+			break
+		}
+	}
+	if n > 0 {
+		pool.getSignal.broadcast()
+	}
+	return
+}
+
+// Get 阻塞地获取数据 直到成功或者缓冲池关闭
+func (pool *ShardedPoolOf[T]) Get() (data T, err error) {
+	return pool.GetContext(context.Background())
+}
+
+// GetTimeout 阻塞地获取数据 最多等待timeout
+func (pool *ShardedPoolOf[T]) GetTimeout(timeout time.Duration) (data T, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return pool.GetContext(ctx)
+}
+
+// GetContext 阻塞地获取数据 所有子缓冲池都为空时等待Put放入数据
+func (pool *ShardedPoolOf[T]) GetContext(ctx context.Context) (data T, err error) {
+	err = pool.getSignal.until(ctx, pool.done, ErrClosedBufferPool, nil, nil, &pool.getWait, func() (bool, error) {
+		data, err = pool.TryGet()
+		return err != ErrBufferEmpty, err
+	})
+	return
+}
+
+// TryGet 非阻塞地获取数据 从轮询的子缓冲池开始 取不到时依次从其它子缓冲池窃取
+func (pool *ShardedPoolOf[T]) TryGet() (data T, err error) {
+	if pool.Closed() {
+		return data, ErrClosedBufferPool
+	}
+
+	start := atomic.AddUint32(&pool.getIndex, 1)
+	for i := range pool.shards {
+		shard := pool.shards[(start+uint32(i))%uint32(len(pool.shards))]
+		if data, err = shard.TryGet(); err != ErrBufferEmpty {
+			break
+		}
+	}
+	if err == nil {
+		pool.putSignal.broadcast()
+	}
+	return
+}
+
+// GetBatch 非阻塞地批量获取最多max个数据 从轮询的子缓冲池开始 不够时依次从其它子缓冲池窃取
+func (pool *ShardedPoolOf[T]) GetBatch(max int) (items []T, err error) {
+	if pool.Closed() {
+		return nil, ErrClosedBufferPool
+	}
+	if max <= 0 {
+		return nil, nil
+	}
+
+	start := atomic.AddUint32(&pool.getIndex, 1)
+	for i := range pool.shards {
+		shard := pool.shards[(start+uint32(i))%uint32(len(pool.shards))]
+		var got []T
+		got, err = shard.GetBatch(max - len(items))
+		items = append(items, got...)
+		if len(items) == max || (err != nil && err != ErrBufferEmpty) {
+			break
+		}
+	}
+	if len(items) > 0 {
+		err = nil
+		pool.putSignal.broadcast()
+	}
+	return
+}
+
+// Close 关闭所有的子缓冲池
+func (pool *ShardedPoolOf[T]) Close() bool {
+	if !atomic.CompareAndSwapUint32(&pool.closed, 0, 1) {
+		return false
+	}
+	for _, shard := range pool.shards {
+		shard.Close()
+	}
+	close(pool.done)
+	return true
+}
+
+// Closed  0-未关闭；1-已关闭
+func (pool *ShardedPoolOf[T]) Closed() bool {
+	return atomic.LoadUint32(&pool.closed) == 1
+}
+
+// Done 返回缓冲池关闭时被关闭的通道
+func (pool *ShardedPoolOf[T]) Done() <-chan struct{} {
+	return pool.done
+}
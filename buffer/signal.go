@@ -0,0 +1,82 @@
+package buffer
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// signal 用于唤醒阻塞在缓冲池上的 Put/Get 调用者。
+// 等待者先通过 wait 取得当前的通知通道再去尝试存取数据，
+// 这样在尝试和等待之间发生的 broadcast 不会被错过。
+type signal struct {
+	// mu 保护通知通道的替换。
+	mu sync.Mutex
+	// ch 代表当前的通知通道 broadcast 时关闭并替换。
+	ch chan struct{}
+	// waiters 代表正在等待的调用者数量 为0时 broadcast 直接返回。
+	waiters int32
+}
+
+func newSignal() *signal {
+	return &signal{ch: make(chan struct{})}
+}
+
+// wait 登记一个等待者并返回当前的通知通道 使用完毕后必须调用 done。
+func (s *signal) wait() <-chan struct{} {
+	atomic.AddInt32(&s.waiters, 1)
+	s.mu.Lock()
+	ch := s.ch
+	s.mu.Unlock()
+	return ch
+}
+
+// done 注销一个等待者。
+func (s *signal) done() {
+	atomic.AddInt32(&s.waiters, -1)
+}
+
+// broadcast 唤醒所有等待者。
+func (s *signal) broadcast() {
+	if atomic.LoadInt32(&s.waiters) == 0 {
+		return
+	}
+	s.mu.Lock()
+	close(s.ch)
+	s.ch = make(chan struct{})
+	s.mu.Unlock()
+}
+
+// until 反复调用try直到它返回true 每次返回false后等待 broadcast 的通知再重试 返回try返回的错误
+// ctx结束时返回ctx.Err() closed被关闭时返回closedErr stop被关闭时返回stopErr
+// closedErr或stopErr为nil时 对应的通道被关闭后继续重试 stop可以为nil
+// waited不为nil时累计等待的时间 单位纳秒
+func (s *signal) until(ctx context.Context, closed <-chan struct{}, closedErr error,
+	stop <-chan struct{}, stopErr error, waited *int64, try func() (bool, error)) error {
+	for {
+		ch := s.wait()
+		if ok, err := try(); ok {
+			s.done()
+			return err
+		}
+		start := time.Now()
+		var err error
+		select {
+		case <-ch:
+		case <-closed:
+			err = closedErr
+		case <-stop:
+			err = stopErr
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		if waited != nil {
+			atomic.AddInt64(waited, int64(time.Since(start)))
+		}
+		s.done()
+		if err != nil {
+			return err
+		}
+	}
+}
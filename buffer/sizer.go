@@ -0,0 +1,94 @@
+package buffer
+
+import "sync/atomic"
+
+// ISizer 可以计算自身字节数的数据 WithMaxBytes 没有指定计算函数时使用
+type ISizer interface {
+	Size() uint64
+}
+
+// WithMaxBytes 按数据的字节数限制缓冲池的容量 和poolCap bufferCap同时生效
+// maxBytes为数据总字节数的上限 为0时只统计不限制
+// sizer计算数据的字节数 为nil时数据需要实现ISizer 否则按0字节计算
+// 放入后超过上限时按缓冲池已满处理 缓冲池为空时单个数据超过上限也可以放入
+func WithMaxBytes[T any](maxBytes uint64, sizer func(data T) uint64) PoolOption[T] {
+	return func(pool *BufferPoolOf[T]) error {
+		if sizer == nil {
+			sizer = func(data T) uint64 {
+				if s, ok := any(data).(ISizer); ok {
+					return s.Size()
+				}
+				return 0
+			}
+		}
+		pool.sizer = sizer
+		pool.maxBytes = maxBytes
+		return nil
+	}
+}
+
+// Bytes 获取池中数据的总字节数 没有使用WithMaxBytes时为0
+func (pool *BufferPoolOf[T]) Bytes() uint64 {
+	return atomic.LoadUint64(&pool.bytes)
+}
+
+// MaxBytes 获取数据总字节数的上限 为0时不限制
+func (pool *BufferPoolOf[T]) MaxBytes() uint64 {
+	return pool.maxBytes
+}
+
+// sizeOf 计算items的总字节数
+func (pool *BufferPoolOf[T]) sizeOf(items ...T) (size uint64) {
+	if pool.sizer == nil {
+		return 0
+	}
+	for _, data := range items {
+		size += pool.sizer(data)
+	}
+	return
+}
+
+// reserveBytes 为放入size字节的数据预留空间 超过上限时返回false
+func (pool *BufferPoolOf[T]) reserveBytes(size uint64) bool {
+	if pool.sizer == nil {
+		return true
+	}
+	for {
+		cur := atomic.LoadUint64(&pool.bytes)
+		if pool.maxBytes > 0 && cur > 0 && cur+size > pool.maxBytes {
+			return false
+		}
+		if atomic.CompareAndSwapUint64(&pool.bytes, cur, cur+size) {
+			return true
+		}
+	}
+}
+
+// reserveBatch 为items中尽量多的前面部分预留空间 返回预留的数据数量
+func (pool *BufferPoolOf[T]) reserveBatch(items []T) int {
+	if pool.sizer == nil {
+		return len(items)
+	}
+	for {
+		cur := atomic.LoadUint64(&pool.bytes)
+		next, fit := cur, 0
+		for _, data := range items {
+			size := pool.sizer(data)
+			if pool.maxBytes > 0 && next > 0 && next+size > pool.maxBytes {
+				break
+			}
+			next += size
+			fit++
+		}
+		if fit == 0 || atomic.CompareAndSwapUint64(&pool.bytes, cur, next) {
+			return fit
+		}
+	}
+}
+
+// releaseBytes 释放size字节的空间
+func (pool *BufferPoolOf[T]) releaseBytes(size uint64) {
+	if size > 0 {
+		atomic.AddUint64(&pool.bytes, ^(size - 1))
+	}
+}
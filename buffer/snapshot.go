@@ -0,0 +1,139 @@
+package buffer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"time"
+)
+
+// ErrBadSnapshot 快照的格式不正确或者已损坏
+var ErrBadSnapshot = errors.New("invalid snapshot")
+
+// snapshotMagic 快照文件头 后面是8字节的数据数量 记录格式同溢出日志
+var snapshotMagic = [8]byte{'S', 'N', 'A', 'K', 'E', 'S', 'N', '1'}
+
+// restoreBatch 恢复快照时一次批量放入的最大数量
+const restoreBatch = 1024
+
+// Snapshot 把缓冲池中当前的数据用codec编码写入w 返回写入的数据数量
+// 复制数据时短暂独占所有的缓冲器 得到某一时刻的一致快照 编码和写入时 Put Get 照常进行
+// 写入顺序同Range 溢出日志中的数据不在快照中
+func (pool *BufferPoolOf[T]) Snapshot(w io.Writer, codec ICodec[T]) (n int, err error) {
+	if pool.Closed() {
+		return 0, ErrClosedBufferPool
+	}
+	items := pool.peekAll()
+
+	bw := bufio.NewWriter(w)
+	var header [16]byte
+	copy(header[:], snapshotMagic[:])
+	binary.BigEndian.PutUint64(header[8:], uint64(len(items)))
+	if _, err = bw.Write(header[:]); err != nil {
+		return 0, err
+	}
+	var record [spillHeaderSize]byte
+	for _, data := range items {
+		payload, err := codec.Encode(data)
+		if err != nil {
+			return n, err
+		}
+		binary.BigEndian.PutUint32(record[:], uint32(len(payload)))
+		binary.BigEndian.PutUint32(record[4:], crc32.ChecksumIEEE(payload))
+		if _, err = bw.Write(record[:]); err != nil {
+			return n, err
+		}
+		if _, err = bw.Write(payload); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, bw.Flush()
+}
+
+// Restore 从r读取Snapshot写入的快照 用codec解码后放入缓冲池 返回放入的数据数量
+// 放入是非阻塞的 缓冲池放不下时返回ErrBufferOverload 之后的数据不再读取
+func (pool *BufferPoolOf[T]) Restore(r io.Reader, codec ICodec[T]) (n int, err error) {
+	br := bufio.NewReader(r)
+	var header [16]byte
+	if _, err = io.ReadFull(br, header[:]); err != nil {
+		return 0, ErrBadSnapshot
+	}
+	if !bytes.Equal(header[:8], snapshotMagic[:]) {
+		return 0, ErrBadSnapshot
+	}
+	count := binary.BigEndian.Uint64(header[8:])
+
+	batch := make([]T, 0, restoreBatch)
+	flush := func() error {
+		put, err := pool.PutBatch(batch)
+		n += put
+		batch = batch[:0]
+		return err
+	}
+	var record [spillHeaderSize]byte
+	for i := uint64(0); i < count; i++ {
+		if _, err = io.ReadFull(br, record[:]); err != nil {
+			return n, ErrBadSnapshot
+		}
+		payload := make([]byte, binary.BigEndian.Uint32(record[:]))
+		if _, err = io.ReadFull(br, payload); err != nil {
+			return n, ErrBadSnapshot
+		}
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(record[4:]) {
+			return n, ErrBadSnapshot
+		}
+		data, err := codec.Decode(payload)
+		if err != nil {
+			return n, err
+		}
+		if batch = append(batch, data); len(batch) == restoreBatch {
+			if err = flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+	if len(batch) > 0 {
+		err = flush()
+	}
+	return n, err
+}
+
+// freeze 独占所有的缓冲器 期间其它的 Put Get 都会等待 返回的缓冲器必须用 thaw 归还
+// 先进先出模式下持有segLock 其它模式下从bufChs取出所有的缓冲器
+func (pool *BufferPoolOf[T]) freeze() (bufs []IBufferOf[T]) {
+	if pool.ordered {
+		pool.segLock.Lock()
+		return pool.segs
+	}
+	//缓冲器可能在等待期间被回收 定期重新检查数量 避免一直等待
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	for uint32(len(bufs)) < pool.Len() {
+		select {
+		case buf, ok := <-pool.bufChs:
+			if !ok {
+				return
+			}
+			bufs = append(bufs, buf)
+		case <-ticker.C:
+		}
+	}
+	return
+}
+
+// thaw 归还freeze独占的缓冲器
+func (pool *BufferPoolOf[T]) thaw(bufs []IBufferOf[T]) {
+	if pool.ordered {
+		pool.segLock.Unlock()
+		return
+	}
+	for _, buf := range bufs {
+		pool.releasePutBuffer(buf)
+	}
+	pool.getSignal.broadcast()
+	pool.putSignal.broadcast()
+}
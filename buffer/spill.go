@@ -0,0 +1,638 @@
+package buffer
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrClosedSpill 溢出日志已关闭
+	ErrClosedSpill = errors.New("spill is closed")
+	// ErrSpillCorrupted 溢出日志中的记录已损坏
+	ErrSpillCorrupted = errors.New("spill record is corrupted")
+)
+
+const (
+	// spillSegmentExt 溢出日志分段文件的扩展名
+	spillSegmentExt = ".seg"
+	// spillCursorFile 保存读取位置的文件名
+	spillCursorFile = "cursor"
+	// spillHeaderSize 每条记录的头部大小: 4字节长度 + 4字节crc32
+	spillHeaderSize = 8
+	// defaultSpillSegmentSize 默认的分段文件大小
+	defaultSpillSegmentSize = 64 << 20
+)
+
+// SpillLog 分段的磁盘溢出日志 先进先出
+// 记录依次追加到最新的分段文件 分段写满后新建分段
+// 读取位置保存在cursor文件中 分段读完后被删除 进程重启后从上次保存的读取位置继续
+// 默认不主动刷盘 进程崩溃不丢数据 系统崩溃时可能丢失最近的写入 见SetSync
+type SpillLog[T any] struct {
+	// lock 保护下面所有字段
+	lock sync.Mutex
+	// dir 代表分段文件所在的目录
+	dir string
+	// segmentSize 代表单个分段文件的最大字节数
+	segmentSize int64
+	// codec 代表数据的编解码器
+	codec ICodec[T]
+
+	// writeSeg writeOff writeFile 代表当前写入的分段和位置
+	writeSeg  uint64
+	writeOff  int64
+	writeFile *os.File
+	// readSeg readOff 代表下一条还没有被读取的记录的位置
+	readSeg uint64
+	readOff int64
+	// commitSeg commitOff 代表保存在cursor文件中的读取位置 之前的记录都已被确认
+	// 读取了还没有确认的记录在两个读取位置之间 重启后会再次被读取
+	commitSeg uint64
+	commitOff int64
+	// readFile readFileSeg 代表最近打开用于读取的分段文件
+	readFile    *os.File
+	readFileSeg uint64
+	// count 代表还没有被读取的记录数量 修改时持有lock 可以原子地读取
+	count uint64
+	// syncing 代表是否主动刷盘 policy syncBatch 代表刷盘策略
+	syncing   bool
+	policy    SyncPolicy
+	syncBatch int
+	// unsynced 代表上次刷盘之后的写入次数
+	unsynced int
+	// err 代表最近一次跳过损坏的记录或者后台刷盘遇到的错误
+	err error
+	// closed 代表是否已关闭
+	closed bool
+	// stop 在关闭时被关闭 用于结束刷盘协程
+	stop chan struct{}
+}
+
+// OpenSpillLog 打开dir目录下的溢出日志 目录不存在时自动创建
+// segmentSize为单个分段文件的最大字节数 为0时使用默认值64M
+// 打开时会校验未读取的记录 并截掉最后一个分段末尾写了一半的记录
+func OpenSpillLog[T any](dir string, segmentSize int64, codec ICodec[T]) (*SpillLog[T], error) {
+	if codec == nil {
+		return nil, errors.New("spill codec is nil")
+	}
+	if segmentSize <= 0 {
+		segmentSize = defaultSpillSegmentSize
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	spill := &SpillLog[T]{dir: dir, segmentSize: segmentSize, codec: codec, stop: make(chan struct{})}
+	segs, err := spill.segments()
+	if err != nil {
+		return nil, err
+	}
+	if len(segs) == 0 {
+		segs = append(segs, 0)
+	}
+	spill.readSeg, spill.readOff = segs[0], 0
+	if seg, off, ok := spill.loadCursor(); ok && seg >= segs[0] {
+		//读取位置所在的分段已不存在 说明之前的记录都已读完
+		if _, err = os.Stat(spill.segmentPath(seg)); err != nil {
+			off = 0
+		}
+		spill.readSeg, spill.readOff = seg, off
+	}
+	spill.commitSeg, spill.commitOff = spill.readSeg, spill.readOff
+	if err = spill.recover(segs); err != nil {
+		return nil, err
+	}
+	return spill, nil
+}
+
+// segments 列出目录下所有分段文件的序号 从小到大
+func (spill *SpillLog[T]) segments() ([]uint64, error) {
+	return listSegments(spill.dir)
+}
+
+func (spill *SpillLog[T]) segmentPath(seg uint64) string {
+	return segmentPath(spill.dir, seg)
+}
+
+// listSegments 列出dir目录下所有分段文件的序号 从小到大
+func listSegments(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var segs []uint64
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, spillSegmentExt) {
+			continue
+		}
+		if seg, err := strconv.ParseUint(strings.TrimSuffix(name, spillSegmentExt), 10, 64); err == nil {
+			segs = append(segs, seg)
+		}
+	}
+	sort.Slice(segs, func(i, j int) bool { return segs[i] < segs[j] })
+	return segs, nil
+}
+
+// segmentPath 获取dir目录下分段seg的文件路径
+func segmentPath(dir string, seg uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%020d%s", seg, spillSegmentExt))
+}
+
+// loadCursor 读取保存的读取位置
+func (spill *SpillLog[T]) loadCursor() (seg uint64, off int64, ok bool) {
+	b, err := os.ReadFile(filepath.Join(spill.dir, spillCursorFile))
+	if err != nil || len(b) != 16 {
+		return 0, 0, false
+	}
+	return binary.BigEndian.Uint64(b), int64(binary.BigEndian.Uint64(b[8:])), true
+}
+
+// saveCursor 保存确认的读取位置 先写临时文件再改名 保证cursor文件完整 主动刷盘时改名前先刷盘
+func (spill *SpillLog[T]) saveCursor() error {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:], spill.commitSeg)
+	binary.BigEndian.PutUint64(b[8:], uint64(spill.commitOff))
+	tmp := filepath.Join(spill.dir, spillCursorFile+".tmp")
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(b[:]); err == nil && spill.syncing {
+		err = f.Sync()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(spill.dir, spillCursorFile))
+}
+
+// recover 删除已读完的分段 统计未读取的记录数量 并打开最后一个分段用于追加
+func (spill *SpillLog[T]) recover(segs []uint64) error {
+	for _, seg := range segs {
+		if seg < spill.readSeg {
+			os.Remove(spill.segmentPath(seg))
+		}
+	}
+
+	last := segs[len(segs)-1]
+	if last < spill.readSeg {
+		last = spill.readSeg
+	}
+	for seg := spill.readSeg; seg <= last; seg++ {
+		off := int64(0)
+		if seg == spill.readSeg {
+			off = spill.readOff
+		}
+		end, n, err := spill.scan(seg, off)
+		if err != nil {
+			return err
+		}
+		spill.count += n
+		if seg == last {
+			//最后一个分段末尾可能有写了一半的记录 截掉后继续追加
+			f, err := os.OpenFile(spill.segmentPath(seg), os.O_CREATE|os.O_WRONLY, 0644)
+			if err != nil {
+				return err
+			}
+			if err = f.Truncate(end); err != nil {
+				f.Close()
+				return err
+			}
+			spill.writeSeg, spill.writeOff, spill.writeFile = seg, end, f
+		}
+	}
+	return nil
+}
+
+// scan 从分段seg的off处开始校验记录 返回最后一条完整记录的结束位置和记录数量
+func (spill *SpillLog[T]) scan(seg uint64, off int64) (end int64, n uint64, err error) {
+	return scanSegment(spill.dir, seg, off)
+}
+
+// scanSegment 从dir目录下分段seg的off处开始校验记录 返回最后一条完整记录的结束位置和记录数量
+// 分段不存在时返回0
+func scanSegment(dir string, seg uint64, off int64) (end int64, n uint64, err error) {
+	f, err := os.Open(segmentPath(dir, seg))
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	end = off
+	for {
+		_, size, e := readRecord(f, end)
+		if e != nil {
+			return end, n, nil
+		}
+		end += size
+		n++
+	}
+}
+
+// readRecord 读取f中off处的一条记录 返回记录内容和记录占用的字节数
+func readRecord(f *os.File, off int64) ([]byte, int64, error) {
+	var header [spillHeaderSize]byte
+	if _, err := f.ReadAt(header[:], off); err != nil {
+		return nil, 0, err
+	}
+	payload := make([]byte, binary.BigEndian.Uint32(header[:]))
+	if _, err := f.ReadAt(payload, off+spillHeaderSize); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, 0, err
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, 0, ErrSpillCorrupted
+	}
+	return payload, spillHeaderSize + int64(len(payload)), nil
+}
+
+// Len 获取还没有被读取的记录数量
+func (spill *SpillLog[T]) Len() uint64 {
+	return atomic.LoadUint64(&spill.count)
+}
+
+// Append 把数据追加到溢出日志 可以作为OverflowSpill策略的溢出处理函数
+func (spill *SpillLog[T]) Append(data T) error {
+	payload, err := spill.codec.Encode(data)
+	if err != nil {
+		return err
+	}
+	record := encodeRecord(payload)
+
+	spill.lock.Lock()
+	defer spill.lock.Unlock()
+	if spill.closed {
+		return ErrClosedSpill
+	}
+	if spill.writeOff >= spill.segmentSize {
+		if err = spill.rotate(); err != nil {
+			return err
+		}
+	}
+	if _, err = spill.writeFile.WriteAt(record, spill.writeOff); err != nil {
+		return err
+	}
+	spill.writeOff += int64(len(record))
+	atomic.AddUint64(&spill.count, 1)
+	return spill.written()
+}
+
+// encodeRecord 给payload加上长度和crc32组成一条记录
+func encodeRecord(payload []byte) []byte {
+	record := make([]byte, spillHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(record, uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:], crc32.ChecksumIEEE(payload))
+	copy(record[spillHeaderSize:], payload)
+	return record
+}
+
+// rotate 关闭当前写入的分段 新建下一个分段
+func (spill *SpillLog[T]) rotate() error {
+	if spill.syncing && spill.unsynced > 0 {
+		if err := spill.sync(); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(spill.segmentPath(spill.writeSeg+1), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	spill.writeFile.Close()
+	spill.writeSeg, spill.writeOff, spill.writeFile = spill.writeSeg+1, 0, f
+	return nil
+}
+
+// Read 读取并移除最多max条记录 损坏的记录被跳过 见Err
+func (spill *SpillLog[T]) Read(max int) ([]T, error) {
+	spill.lock.Lock()
+	defer spill.lock.Unlock()
+	items, offs, err := spill.peek(max)
+	if len(items) > 0 {
+		spill.advance(len(items), offs[len(items)-1])
+	}
+	if e := spill.commit(); err == nil {
+		err = e
+	}
+	return items, err
+}
+
+// spillOffset 记录结束后的读取位置
+type spillOffset struct {
+	seg uint64
+	off int64
+}
+
+// peek 从读取位置开始读取最多max条记录 不移动读取位置
+// 同时返回每条记录结束后的读取位置 交给advance移动读取位置 调用方必须持有lock
+// 读取位置上的记录损坏时跳过它 记录到err中 已读到数据时停在损坏的记录之前 留给下一次跳过
+func (spill *SpillLog[T]) peek(max int) (items []T, offs []spillOffset, err error) {
+	if spill.closed {
+		return nil, nil, ErrClosedSpill
+	}
+
+	seg, off := spill.readSeg, spill.readOff
+	for len(items) < max && uint64(len(items)) < atomic.LoadUint64(&spill.count) {
+		if seg == spill.writeSeg && off >= spill.writeOff {
+			break
+		}
+		f, e := spill.openRead(seg)
+		if e != nil {
+			return items, offs, e
+		}
+		payload, size, e := readRecord(f, off)
+		if e == io.EOF && seg < spill.writeSeg {
+			//当前分段已读完 转到下一个分段
+			seg, off = seg+1, 0
+			continue
+		}
+		if e == ErrSpillCorrupted || e == io.EOF || e == io.ErrUnexpectedEOF {
+			//记录损坏 不知道下一条记录的位置 只能跳过分段中剩余的记录
+			if len(items) > 0 {
+				return
+			}
+			spill.err = e
+			if e = spill.skipSegment(); e != nil {
+				return items, offs, e
+			}
+			seg, off = spill.readSeg, spill.readOff
+			continue
+		}
+		if e != nil {
+			return items, offs, e
+		}
+		data, e := spill.codec.Decode(payload)
+		if e != nil {
+			//记录完整但无法解码 只跳过这一条
+			if len(items) > 0 {
+				return
+			}
+			spill.err = e
+			spill.advance(1, spillOffset{seg: seg, off: off + size})
+			off += size
+			continue
+		}
+		off += size
+		items = append(items, data)
+		offs = append(offs, spillOffset{seg: seg, off: off})
+	}
+	return
+}
+
+// openRead 打开分段seg用于读取 已打开时直接复用
+// 读取总是从前往后进行 所以只缓存最近打开的一个分段 调用方必须持有lock
+func (spill *SpillLog[T]) openRead(seg uint64) (*os.File, error) {
+	if spill.readFile != nil && seg == spill.readFileSeg {
+		return spill.readFile, nil
+	}
+	f, err := os.Open(spill.segmentPath(seg))
+	if err != nil {
+		return nil, err
+	}
+	if spill.readFile != nil {
+		spill.readFile.Close()
+	}
+	spill.readFile, spill.readFileSeg = f, seg
+	return f, nil
+}
+
+// advance 把读取位置移动到peek读到的前n条记录之后的to 还没有确认 调用方必须持有lock
+func (spill *SpillLog[T]) advance(n int, to spillOffset) {
+	spill.readSeg, spill.readOff = to.seg, to.off
+	atomic.AddUint64(&spill.count, ^uint64(n-1))
+}
+
+// skipSegment 跳过读取位置所在分段中剩余的记录 重新统计还没有被读取的记录数量 调用方必须持有lock
+func (spill *SpillLog[T]) skipSegment() error {
+	if spill.readSeg < spill.writeSeg {
+		spill.readSeg, spill.readOff = spill.readSeg+1, 0
+	} else {
+		spill.readOff = spill.writeOff
+	}
+	var count uint64
+	for seg := spill.readSeg; seg <= spill.writeSeg; seg++ {
+		off := int64(0)
+		if seg == spill.readSeg {
+			off = spill.readOff
+		}
+		_, n, err := spill.scan(seg, off)
+		if err != nil {
+			return err
+		}
+		count += n
+	}
+	atomic.StoreUint64(&spill.count, count)
+	return nil
+}
+
+// commit 确认读取位置之前的记录都已被取走 删除已读完的分段并保存读取位置 调用方必须持有lock
+func (spill *SpillLog[T]) commit() error {
+	if spill.closed || spill.commitSeg == spill.readSeg && spill.commitOff == spill.readOff {
+		return nil
+	}
+	if spill.readFile != nil && spill.readFileSeg < spill.readSeg {
+		spill.readFile.Close()
+		spill.readFile = nil
+	}
+	for ; spill.commitSeg < spill.readSeg; spill.commitSeg++ {
+		os.Remove(spill.segmentPath(spill.commitSeg))
+	}
+	spill.commitOff = spill.readOff
+	return spill.saveCursor()
+}
+
+// SetSync 设置刷盘策略 默认不主动刷盘
+// SyncAlways 每次追加后刷盘 SyncBatch 每累计batch次追加刷盘一次 SyncInterval 后台协程每隔interval刷盘一次
+// batch和interval为0时使用默认值100和1秒 主动刷盘时保存读取位置也会刷盘
+func (spill *SpillLog[T]) SetSync(policy SyncPolicy, batch int, interval time.Duration) error {
+	if policy < SyncAlways || policy > SyncInterval || batch < 0 || interval < 0 {
+		errMsg := fmt.Sprintf("invalid spill sync: policy(%v) batch(%d) interval(%v)", policy, batch, interval)
+		return errors.New(errMsg)
+	}
+	if batch == 0 {
+		batch = defaultWALSyncBatch
+	}
+	if interval == 0 {
+		interval = defaultWALSyncInterval
+	}
+
+	spill.lock.Lock()
+	defer spill.lock.Unlock()
+	if spill.closed {
+		return ErrClosedSpill
+	}
+	if spill.syncing {
+		return errors.New("spill sync is already set")
+	}
+	spill.syncing, spill.policy, spill.syncBatch = true, policy, batch
+	if policy == SyncInterval {
+		go spill.syncLoop(interval)
+	}
+	return nil
+}
+
+// written 按刷盘策略在一次追加后刷盘 调用方必须持有lock
+func (spill *SpillLog[T]) written() error {
+	if !spill.syncing {
+		return nil
+	}
+	spill.unsynced++
+	switch spill.policy {
+	case SyncAlways:
+		return spill.sync()
+	case SyncBatch:
+		if spill.unsynced >= spill.syncBatch {
+			return spill.sync()
+		}
+	}
+	return nil
+}
+
+// sync 把当前写入的分段刷盘 调用方必须持有lock
+func (spill *SpillLog[T]) sync() error {
+	spill.unsynced = 0
+	return spill.writeFile.Sync()
+}
+
+// syncLoop 定时刷盘 直到关闭
+func (spill *SpillLog[T]) syncLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			spill.lock.Lock()
+			if !spill.closed && spill.unsynced > 0 {
+				if err := spill.sync(); err != nil {
+					spill.err = err
+				}
+			}
+			spill.lock.Unlock()
+		case <-spill.stop:
+			return
+		}
+	}
+}
+
+// Err 获取最近一次跳过损坏的记录或者后台刷盘遇到的错误
+func (spill *SpillLog[T]) Err() error {
+	spill.lock.Lock()
+	defer spill.lock.Unlock()
+	return spill.err
+}
+
+// Close 关闭溢出日志 未读取的记录保留在磁盘上 下次打开时继续读取
+// 读取了还没有确认的记录 下次打开时也会再次被读取
+func (spill *SpillLog[T]) Close() error {
+	spill.lock.Lock()
+	defer spill.lock.Unlock()
+	if spill.closed {
+		return ErrClosedSpill
+	}
+	spill.closed = true
+	close(spill.stop)
+	if spill.readFile != nil {
+		spill.readFile.Close()
+	}
+	var err error
+	if spill.syncing && spill.unsynced > 0 {
+		err = spill.sync()
+	}
+	if e := spill.writeFile.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// spillRefillBatch 每次从溢出日志放回内存的最大记录数
+const spillRefillBatch = 4096
+
+// WithSpill 缓冲池已满时把数据追加到磁盘溢出日志 内存中的数据取完时 Get 会自动把数据放回内存
+// 溢出日志中还有数据时 新放入的数据也追加到溢出日志 TryPut 和 PutBatch 返回ErrBufferOverload
+// 溢出日志中的数据按写入顺序放回 进程重启后用同一目录打开的溢出日志继续放回
+// 内存中的数据取完后才确认放回的数据已被取走并保存读取位置 放回后还没有被取走的数据重启后会再次放回 即至少一次
+// 缓冲池关闭时会关闭溢出日志 直接放入内存的数据丢弃 溢出日志中没有确认的数据保留在磁盘上
+// 一个溢出日志只能用于一个缓冲池 分片缓冲池和消费组主题不接受该配置 返回ErrSharedSpill
+func WithSpill[T any](spill *SpillLog[T]) PoolOption[T] {
+	return func(pool *BufferPoolOf[T]) error {
+		if spill == nil {
+			return errors.New("spill log is nil")
+		}
+		pool.spill = spill
+		return pool.SetOverflowPolicy(OverflowSpill, spill.Append)
+	}
+}
+
+// SpillLen 获取溢出日志中还没有放回内存的数据数量
+func (pool *BufferPoolOf[T]) SpillLen() uint64 {
+	if pool.spill == nil {
+		return 0
+	}
+	return pool.spill.Len()
+}
+
+// spilling 溢出日志中还有数据时 新数据也要追加到溢出日志 保证按写入顺序放回
+func (pool *BufferPoolOf[T]) spilling() bool {
+	return pool.spill != nil && pool.spill.Len() > 0
+}
+
+// refillFromSpill 内存中的数据取完时确认之前放回的数据 再把溢出日志中的数据放回内存 返回是否放回了数据
+// err为刚才从内存获取数据的结果
+func (pool *BufferPoolOf[T]) refillFromSpill(err error) bool {
+	if err != ErrBufferEmpty && (err != nil || pool.Total() > 0) {
+		return false
+	}
+
+	spill := pool.spill
+	spill.lock.Lock()
+	defer spill.lock.Unlock()
+	if spill.closed || pool.Closed() {
+		return false
+	}
+	//放回内存的数据都已被取走 才能移动保存的读取位置
+	total := pool.Total()
+	if total == 0 {
+		if e := spill.commit(); e != nil {
+			spill.err = e
+		}
+	}
+	capacity := uint64(pool.Cap()) * uint64(pool.BufferCap())
+	if err != ErrBufferEmpty || spill.Len() == 0 || total >= capacity {
+		return false
+	}
+	free := capacity - total
+	if free > spillRefillBatch {
+		free = spillRefillBatch
+	}
+	items, offs, e := spill.peek(int(free))
+	if e != nil {
+		spill.err = e
+	}
+	if len(items) == 0 {
+		return false
+	}
+	//放不下的数据留在溢出日志中 下次再放回
+	n, _ := pool.putItems(items, false)
+	if n > 0 {
+		spill.advance(n, offs[n-1])
+	}
+	return n > 0
+}
@@ -0,0 +1,125 @@
+package buffer
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// PoolStats 缓冲池的运行统计快照
+type PoolStats struct {
+	// Puts 放入的数据总数
+	Puts uint64
+	// Gets 取出的数据总数
+	Gets uint64
+	// Overloads 放入时缓冲池已满的次数
+	Overloads uint64
+	// Dropped Evicted Spilled 各溢出策略处理的数据数量 见OverflowPolicy
+	Dropped uint64
+	Evicted uint64
+	Spilled uint64
+	// Expired 过期被丢弃的数据数量 只有TTLPoolOf会统计
+	Expired uint64
+	// BuffersCreated 创建缓冲器的总数
+	BuffersCreated uint64
+	// BuffersDestroyed 回收缓冲器的总数
+	BuffersDestroyed uint64
+	// Buffers 当前缓冲器的数量
+	Buffers uint32
+	// Cap 缓冲器的最大数量
+	Cap uint32
+	// BufferCap 缓冲器的统一容量
+	BufferCap uint32
+	// Total 当前数据总数
+	Total uint64
+	// HighWaterTotal 数据总数的历史最大值
+	HighWaterTotal uint64
+	// Bytes 当前数据的总字节数 见WithMaxBytes
+	Bytes uint64
+	// MaxBytes 数据总字节数的上限 为0时不限制
+	MaxBytes uint64
+	// PutWait Put 阻塞等待的累计时间
+	PutWait time.Duration
+	// GetWait Get 阻塞等待的累计时间
+	GetWait time.Duration
+}
+
+// Stats 获取缓冲池的运行统计快照 各项数据分别原子读取 相互之间不保证严格一致
+func (pool *BufferPoolOf[T]) Stats() PoolStats {
+	return PoolStats{
+		Puts:             atomic.LoadUint64(&pool.putSize),
+		Gets:             atomic.LoadUint64(&pool.getSize),
+		Overloads:        atomic.LoadUint64(&pool.overloads),
+		Dropped:          atomic.LoadUint64(&pool.dropped),
+		Evicted:          atomic.LoadUint64(&pool.evicted),
+		Spilled:          atomic.LoadUint64(&pool.spilled),
+		BuffersCreated:   atomic.LoadUint64(&pool.created),
+		BuffersDestroyed: atomic.LoadUint64(&pool.destroyed),
+		Buffers:          pool.Len(),
+		Cap:              pool.Cap(),
+		BufferCap:        pool.BufferCap(),
+		Total:            pool.Total(),
+		HighWaterTotal:   atomic.LoadUint64(&pool.highWater),
+		Bytes:            pool.Bytes(),
+		MaxBytes:         pool.MaxBytes(),
+		PutWait:          time.Duration(atomic.LoadInt64(&pool.putWait)),
+		GetWait:          time.Duration(atomic.LoadInt64(&pool.getWait)),
+	}
+}
+
+// addPut 统计放入了n个数据 并更新数据总数的历史最大值
+func (pool *BufferPoolOf[T]) addPut(n uint64) {
+	total := atomic.AddUint64(&pool.total, n)
+	atomic.AddUint64(&pool.putSize, n)
+	pool.checkTotal(total)
+	for {
+		high := atomic.LoadUint64(&pool.highWater)
+		if total <= high || atomic.CompareAndSwapUint64(&pool.highWater, high, total) {
+			return
+		}
+	}
+}
+
+// addGet 统计取出了items 并释放它们占用的字节数
+func (pool *BufferPoolOf[T]) addGet(items ...T) {
+	n := uint64(len(items))
+	total := atomic.AddUint64(&pool.total, ^(n - 1))
+	atomic.AddUint64(&pool.getSize, n)
+	pool.releaseBytes(pool.sizeOf(items...))
+	pool.checkTotal(total)
+}
+
+// removeBuffer 统计回收了一个缓冲器
+func (pool *BufferPoolOf[T]) removeBuffer() {
+	size := atomic.AddUint32(&pool.poolSize, ^uint32(0))
+	atomic.AddUint64(&pool.destroyed, 1)
+	pool.checkBuffers(size)
+}
+
+// Stats 汇总所有子缓冲池的统计 HighWaterTotal 为各子缓冲池历史最大值之和
+func (pool *ShardedPoolOf[T]) Stats() PoolStats {
+	var stats PoolStats
+	for _, shard := range pool.shards {
+		s := shard.Stats()
+		stats.Puts += s.Puts
+		stats.Gets += s.Gets
+		stats.Overloads += s.Overloads
+		stats.Dropped += s.Dropped
+		stats.Evicted += s.Evicted
+		stats.Spilled += s.Spilled
+		stats.Expired += s.Expired
+		stats.BuffersCreated += s.BuffersCreated
+		stats.BuffersDestroyed += s.BuffersDestroyed
+		stats.Buffers += s.Buffers
+		stats.Cap += s.Cap
+		stats.BufferCap = s.BufferCap
+		stats.Total += s.Total
+		stats.HighWaterTotal += s.HighWaterTotal
+		stats.Bytes += s.Bytes
+		stats.MaxBytes += s.MaxBytes
+		stats.PutWait += s.PutWait
+		stats.GetWait += s.GetWait
+	}
+	stats.PutWait += time.Duration(atomic.LoadInt64(&pool.putWait))
+	stats.GetWait += time.Duration(atomic.LoadInt64(&pool.getWait))
+	return stats
+}
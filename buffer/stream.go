@@ -0,0 +1,83 @@
+package buffer
+
+import "context"
+
+// IDone 关闭时可以得到通知的缓冲池 In 用它在缓冲池关闭时退出
+type IDone interface {
+	// Done 返回缓冲池关闭时被关闭的通道
+	Done() <-chan struct{}
+}
+
+// Out 返回从缓冲池读取数据的通道 可以直接用于select
+// 后台协程不断 Get 数据并发送到通道 ctx 结束或者缓冲池关闭时关闭通道
+// ctx 结束时已经取出还没有被接收的数据会用 TryPut 放回缓冲池 顺序可能改变
+// 放不回去时(例如缓冲池已满)把数据和错误交给onError onError为nil时丢弃
+func Out[T any](ctx context.Context, pool IPoolOf[T], onError func(data T, err error)) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for {
+			data, err := pool.GetContext(ctx)
+			if err != nil {
+				return
+			}
+			select {
+			case out <- data:
+			case <-ctx.Done():
+				if _, err = pool.TryPut(data); err != nil && onError != nil {
+					onError(data, err)
+				}
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// In 返回向缓冲池写入数据的通道 发送到in的数据由后台协程 Put 到缓冲池
+// 调用方关闭in ctx 结束或者缓冲池关闭时后台协程退出并关闭done 之后不会再从in接收数据
+// 发送时应同时等待done 例如 select { case in <- data: case <-done: }
+// Put 失败的数据和错误交给onError 包括被溢出策略丢弃 缓冲池正在关闭或已关闭 以及ctx结束 onError为nil时丢弃
+// 缓冲池正在关闭或已关闭以及ctx结束时后台协程随后退出
+func In[T any](ctx context.Context, pool IPoolOf[T], onError func(data T, err error)) (in chan<- T, done <-chan struct{}) {
+	ch := make(chan T)
+	stop := make(chan struct{})
+	var closed <-chan struct{}
+	if d, ok := pool.(IDone); ok {
+		closed = d.Done()
+	}
+	go func() {
+		defer close(stop)
+		for {
+			select {
+			case data, ok := <-ch:
+				if !ok {
+					return
+				}
+				//溢出策略丢弃的数据不影响后续数据
+				_, err := pool.PutContext(ctx, data)
+				if err != nil && onError != nil {
+					onError(data, err)
+				}
+				if err == ErrClosedBufferPool || err == ErrDrainingBufferPool || ctx.Err() != nil {
+					return
+				}
+			case <-ctx.Done():
+				return
+			case <-closed:
+				return
+			}
+		}
+	}()
+	return ch, stop
+}
+
+// Out 返回从缓冲池读取数据的通道 见Out函数
+func (pool *BufferPoolOf[T]) Out(ctx context.Context, onError func(data T, err error)) <-chan T {
+	return Out[T](ctx, pool, onError)
+}
+
+// In 返回向缓冲池写入数据的通道 见In函数
+func (pool *BufferPoolOf[T]) In(ctx context.Context, onError func(data T, err error)) (in chan<- T, done <-chan struct{}) {
+	return In[T](ctx, pool, onError)
+}
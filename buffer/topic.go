@@ -0,0 +1,319 @@
+package buffer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrClosedTopic 是表示主题已关闭的错误的变量。
+var ErrClosedTopic = errors.New("topic is closed")
+
+// SlowPolicy 订阅者的缓冲器已满时 Publish 的处理策略
+type SlowPolicy int32
+
+const (
+	// SlowDrop 丢弃发给该订阅者的数据 计入订阅者的Dropped 默认策略
+	SlowDrop SlowPolicy = iota
+	// SlowBlock 阻塞发布者 直到该订阅者有空间 取消订阅或者ctx结束
+	SlowBlock
+	// SlowDisconnect 取消该订阅者的订阅 它仍可以取完缓冲器中剩余的数据
+	SlowDisconnect
+)
+
+var slowPolicyNames = [...]string{"drop", "block", "disconnect"}
+
+func (p SlowPolicy) String() string {
+	if p < 0 || int(p) >= len(slowPolicyNames) {
+		return "unknown"
+	}
+	return slowPolicyNames[p]
+}
+
+// Topic 存放interface{}数据的主题
+type Topic = TopicOf[interface{}]
+
+// Subscriber 存放interface{}数据的订阅者
+type Subscriber = SubscriberOf[interface{}]
+
+// TopicOf 发布订阅主题
+// 每个订阅者有自己的缓冲器 Publish 把数据放入所有订阅者的缓冲器
+// 订阅者的缓冲器已满时按SlowPolicy处理
+type TopicOf[T any] struct {
+	// bufferCap 代表订阅者缓冲器的容量
+	bufferCap uint32
+	// policy 代表订阅者的缓冲器已满时的处理策略
+	policy SlowPolicy
+	// lock 保护subs和nextID
+	lock sync.RWMutex
+	// subs 代表当前的订阅者 按订阅编号索引
+	subs map[uint64]*SubscriberOf[T]
+	// nextID 代表上一个订阅者的编号
+	nextID uint64
+	// closed 代表主题的关闭状态：0-未关闭；1-已关闭。
+	closed uint32
+	// published 代表发布的数据数量
+	published uint64
+	// disconnected 代表SlowDisconnect策略下被取消订阅的订阅者数量
+	disconnected uint64
+}
+
+// SubscriberOf 主题的订阅者 取消订阅后仍可以取完缓冲器中剩余的数据 之后返回ErrClosedBuffer
+type SubscriberOf[T any] struct {
+	// id 代表订阅编号
+	id uint64
+	// topic 代表订阅的主题
+	topic *TopicOf[T]
+	// buf 代表存放发给该订阅者的数据的缓冲器
+	buf IBufferOf[T]
+	// putSignal 用于唤醒等待空间的 Publish 调用者。
+	putSignal *signal
+	// getSignal 用于唤醒等待数据的 Get 调用者。
+	getSignal *signal
+	// done 在取消订阅时被关闭 用于唤醒所有阻塞的调用者。
+	done chan struct{}
+	// dropped 代表SlowDrop策略下丢弃的数据数量
+	dropped uint64
+}
+
+// NewTopic 用于创建一个主题 参数含义同NewTopicOf
+func NewTopic(bufferCap uint32, policy SlowPolicy) (*Topic, error) {
+	return NewTopicOf[interface{}](bufferCap, policy)
+}
+
+// NewTopicOf 用于创建一个存放T类型数据的主题
+// bufferCap为每个订阅者缓冲器的容量 policy为订阅者的缓冲器已满时的处理策略
+func NewTopicOf[T any](bufferCap uint32, policy SlowPolicy) (*TopicOf[T], error) {
+	if bufferCap == 0 || policy < SlowDrop || policy > SlowDisconnect {
+		errMsg := fmt.Sprintf("invalid params for topic: bufferCap(%d) policy(%v)", bufferCap, policy)
+		return nil, errors.New(errMsg)
+	}
+	return &TopicOf[T]{
+		bufferCap: bufferCap,
+		policy:    policy,
+		subs:      make(map[uint64]*SubscriberOf[T]),
+	}, nil
+}
+
+var topicFmtMsg = "policy(%v) bufCap(%d) subscribers(%d) published(%d)"
+
+func (topic *TopicOf[T]) String() string {
+	return fmt.Sprintf(topicFmtMsg, topic.policy, topic.bufferCap, topic.Subscribers(), topic.Published())
+}
+
+// Subscribe 新建一个订阅者 只能收到订阅之后发布的数据
+func (topic *TopicOf[T]) Subscribe() (*SubscriberOf[T], error) {
+	buf, err := NewBufferOf[T](topic.bufferCap)
+	if err != nil {
+		return nil, err
+	}
+	topic.lock.Lock()
+	defer topic.lock.Unlock()
+	if topic.Closed() {
+		return nil, ErrClosedTopic
+	}
+	topic.nextID++
+	sub := &SubscriberOf[T]{
+		id:        topic.nextID,
+		topic:     topic,
+		buf:       buf,
+		putSignal: newSignal(),
+		getSignal: newSignal(),
+		done:      make(chan struct{}),
+	}
+	topic.subs[sub.id] = sub
+	return sub, nil
+}
+
+// Unsubscribe 取消订阅并关闭订阅者的缓冲器 订阅者已取消订阅时返回false
+func (topic *TopicOf[T]) Unsubscribe(sub *SubscriberOf[T]) bool {
+	topic.lock.Lock()
+	_, ok := topic.subs[sub.id]
+	delete(topic.subs, sub.id)
+	topic.lock.Unlock()
+	if ok {
+		sub.close()
+	}
+	return ok
+}
+
+// Subscribers 获取当前订阅者的数量
+func (topic *TopicOf[T]) Subscribers() int {
+	topic.lock.RLock()
+	defer topic.lock.RUnlock()
+	return len(topic.subs)
+}
+
+// Published 获取发布的数据数量
+func (topic *TopicOf[T]) Published() uint64 {
+	return atomic.LoadUint64(&topic.published)
+}
+
+// Disconnected 获取SlowDisconnect策略下被取消订阅的订阅者数量
+func (topic *TopicOf[T]) Disconnected() uint64 {
+	return atomic.LoadUint64(&topic.disconnected)
+}
+
+// Publish 把数据发给所有订阅者 返回收到数据的订阅者数量
+// SlowBlock策略下会一直等到所有订阅者都有空间为止
+func (topic *TopicOf[T]) Publish(data T) (n int, err error) {
+	return topic.PublishContext(context.Background(), data)
+}
+
+// PublishContext 把数据发给所有订阅者 返回收到数据的订阅者数量
+// SlowBlock策略下ctx结束时返回ctx.Err() 此时已经收到数据的订阅者不会撤回
+func (topic *TopicOf[T]) PublishContext(ctx context.Context, data T) (n int, err error) {
+	//先复制订阅者 阻塞等待时不能持有锁 否则 Unsubscribe 无法唤醒
+	topic.lock.RLock()
+	if topic.Closed() {
+		topic.lock.RUnlock()
+		return 0, ErrClosedTopic
+	}
+	subs := make([]*SubscriberOf[T], 0, len(topic.subs))
+	for _, sub := range topic.subs {
+		subs = append(subs, sub)
+	}
+	topic.lock.RUnlock()
+
+	atomic.AddUint64(&topic.published, 1)
+	for _, sub := range subs {
+		var ok bool
+		switch topic.policy {
+		case SlowBlock:
+			if ok, err = sub.putContext(ctx, data); err != nil && err != ErrClosedBuffer {
+				return n, err
+			}
+		case SlowDisconnect:
+			if ok = sub.put(data); !ok && topic.Unsubscribe(sub) {
+				atomic.AddUint64(&topic.disconnected, 1)
+			}
+		default:
+			if ok = sub.put(data); !ok && !sub.Closed() {
+				atomic.AddUint64(&sub.dropped, 1)
+			}
+		}
+		if ok {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Close 关闭主题并取消所有订阅 若主题之前已关闭则返回false
+func (topic *TopicOf[T]) Close() bool {
+	topic.lock.Lock()
+	if !atomic.CompareAndSwapUint32(&topic.closed, 0, 1) {
+		topic.lock.Unlock()
+		return false
+	}
+	subs := topic.subs
+	topic.subs = make(map[uint64]*SubscriberOf[T])
+	topic.lock.Unlock()
+	for _, sub := range subs {
+		sub.close()
+	}
+	return true
+}
+
+// Closed 用于判断主题是否已关闭
+func (topic *TopicOf[T]) Closed() bool {
+	return atomic.LoadUint32(&topic.closed) == 1
+}
+
+// put 非阻塞地放入数据 成功时唤醒等待数据的 Get 调用者
+func (sub *SubscriberOf[T]) put(data T) bool {
+	ok, _ := sub.buf.Put(data)
+	if ok {
+		sub.getSignal.broadcast()
+	}
+	return ok
+}
+
+// putContext 阻塞地放入数据 取消订阅时返回ErrClosedBuffer
+func (sub *SubscriberOf[T]) putContext(ctx context.Context, data T) (ok bool, err error) {
+	err = sub.putSignal.until(ctx, sub.done, ErrClosedBuffer, nil, nil, nil, func() (bool, error) {
+		if ok, err = sub.buf.Put(data); ok {
+			sub.getSignal.broadcast()
+		}
+		return err != ErrBufferOverload, err
+	})
+	return
+}
+
+// close 关闭订阅者的缓冲器并唤醒所有阻塞的调用者
+func (sub *SubscriberOf[T]) close() {
+	if sub.buf.Close() {
+		close(sub.done)
+	}
+}
+
+// ID 获取订阅编号
+func (sub *SubscriberOf[T]) ID() uint64 {
+	return sub.id
+}
+
+// Cap 获取订阅者缓冲器的容量
+func (sub *SubscriberOf[T]) Cap() uint32 {
+	return sub.buf.Cap()
+}
+
+// Len 获取订阅者缓冲器中的数据数量
+func (sub *SubscriberOf[T]) Len() uint32 {
+	return sub.buf.Len()
+}
+
+// Dropped 获取SlowDrop策略下丢弃的发给该订阅者的数据数量
+func (sub *SubscriberOf[T]) Dropped() uint64 {
+	return atomic.LoadUint64(&sub.dropped)
+}
+
+// Get 阻塞地获取数据 直到有数据或者取消订阅后数据已取完
+func (sub *SubscriberOf[T]) Get() (data T, err error) {
+	return sub.GetContext(context.Background())
+}
+
+// GetTimeout 阻塞地获取数据 最多等待timeout
+func (sub *SubscriberOf[T]) GetTimeout(timeout time.Duration) (data T, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return sub.GetContext(ctx)
+}
+
+// GetContext 阻塞地获取数据 ctx 结束时返回ctx.Err() 取消订阅后数据已取完时返回ErrClosedBuffer
+func (sub *SubscriberOf[T]) GetContext(ctx context.Context) (data T, err error) {
+	//取消订阅后继续重试 取完剩余的数据后TryGet返回ErrClosedBuffer
+	err = sub.getSignal.until(ctx, sub.done, nil, nil, nil, nil, func() (bool, error) {
+		data, err = sub.TryGet()
+		return err != ErrBufferEmpty, err
+	})
+	return
+}
+
+// TryGet 非阻塞地获取数据 没有数据时返回ErrBufferEmpty
+func (sub *SubscriberOf[T]) TryGet() (data T, err error) {
+	if data, err = sub.buf.Get(); err == nil {
+		sub.putSignal.broadcast()
+	}
+	return
+}
+
+// GetBatch 非阻塞地批量获取最多max个数据
+func (sub *SubscriberOf[T]) GetBatch(max int) (items []T, err error) {
+	if items, err = sub.buf.GetBatch(max); len(items) > 0 {
+		sub.putSignal.broadcast()
+	}
+	return
+}
+
+// Unsubscribe 取消订阅 见TopicOf.Unsubscribe
+func (sub *SubscriberOf[T]) Unsubscribe() bool {
+	return sub.topic.Unsubscribe(sub)
+}
+
+// Closed 用于判断是否已取消订阅
+func (sub *SubscriberOf[T]) Closed() bool {
+	return sub.buf.Closed()
+}
@@ -0,0 +1,298 @@
+package buffer
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// TTLItem 带过期时间的数据 Deadline为零值时永不过期
+type TTLItem[T any] struct {
+	Value    T
+	Deadline time.Time
+}
+
+// Expired 判断数据在now时是否已过期
+func (item *TTLItem[T]) Expired(now time.Time) bool {
+	return !item.Deadline.IsZero() && !now.Before(item.Deadline)
+}
+
+// TTLPool 存放interface{}数据的过期缓冲池
+type TTLPool = TTLPoolOf[interface{}]
+
+// TTLPoolOf 过期缓冲池 实现IPoolOf接口
+// 数据放入时打上过期时间 Get 会跳过并丢弃已过期的数据
+// 后台清理协程定期删除过期数据 即使没有人调用 Get 也能保证Total准确
+type TTLPoolOf[T any] struct {
+	// pool 代表实际存放数据的缓冲池
+	pool *BufferPoolOf[TTLItem[T]]
+	// defaultTTL 代表 Put 等方法使用的过期时长 为0时永不过期
+	defaultTTL time.Duration
+	// onExpire 代表数据过期被丢弃时的回调函数 可以为nil
+	onExpire func(data T)
+	// expired 代表过期被丢弃的数据数量
+	expired uint64
+	// stop 在缓冲池关闭时被关闭 用于结束清理协程
+	stop chan struct{}
+}
+
+// NewTTLPool 用于创建一个存放interface{}数据的过期缓冲池 参数含义同NewPool和NewTTLPoolOf
+func NewTTLPool(poolCap uint32, bufferCap uint32, defaultTTL, reapInterval time.Duration) (IPool, error) {
+	pool, err := NewPoolOf[TTLItem[interface{}]](poolCap, bufferCap)
+	if err != nil {
+		return nil, err
+	}
+	return NewTTLPoolOf(pool, defaultTTL, reapInterval, nil), nil
+}
+
+// NewTTLPoolOf 用于在pool之上创建过期缓冲池 pool由过期缓冲池独占 关闭过期缓冲池时一同关闭
+// defaultTTL为 Put 等方法使用的过期时长 为0时永不过期
+// reapInterval为后台清理过期数据的间隔 为0时不启动清理协程 只在 Get 时丢弃
+// onExpire在数据过期被丢弃时调用 可以为nil 清理协程调用时持有缓冲器 回调中不要访问本缓冲池
+func NewTTLPoolOf[T any](pool *BufferPoolOf[TTLItem[T]], defaultTTL, reapInterval time.Duration,
+	onExpire func(data T)) *TTLPoolOf[T] {
+	ttlPool := &TTLPoolOf[T]{
+		pool:       pool,
+		defaultTTL: defaultTTL,
+		onExpire:   onExpire,
+		stop:       make(chan struct{}),
+	}
+	if reapInterval > 0 {
+		go ttlPool.reapLoop(reapInterval)
+	}
+	return ttlPool
+}
+
+// reapLoop 定期清理过期数据 直到缓冲池关闭 直接关闭被包装的缓冲池时也退出
+func (pool *TTLPoolOf[T]) reapLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			pool.Reap()
+		case <-pool.stop:
+			return
+		case <-pool.pool.Done():
+			return
+		}
+	}
+}
+
+// Reap 立即删除缓冲池中所有已过期的数据 返回删除的数量
+// 其它协程正在使用的缓冲器本次跳过
+func (pool *TTLPoolOf[T]) Reap() uint64 {
+	now := time.Now()
+	return pool.pool.removeIf(func(item TTLItem[T]) bool {
+		return item.Expired(now)
+	}, func(item TTLItem[T]) {
+		pool.expire(item)
+	})
+}
+
+// expire 统计并回调一个过期的数据
+func (pool *TTLPoolOf[T]) expire(item TTLItem[T]) {
+	atomic.AddUint64(&pool.expired, 1)
+	if pool.onExpire != nil {
+		pool.onExpire(item.Value)
+	}
+}
+
+// stamp 用ttl给数据打上过期时间 ttl为0时永不过期
+func (pool *TTLPoolOf[T]) stamp(data T, ttl time.Duration) TTLItem[T] {
+	item := TTLItem[T]{Value: data}
+	if ttl > 0 {
+		item.Deadline = time.Now().Add(ttl)
+	}
+	return item
+}
+
+// Expired 获取过期被丢弃的数据数量
+func (pool *TTLPoolOf[T]) Expired() uint64 {
+	return atomic.LoadUint64(&pool.expired)
+}
+
+func (pool *TTLPoolOf[T]) Cap() uint32 {
+	return pool.pool.Cap()
+}
+
+func (pool *TTLPoolOf[T]) Len() uint32 {
+	return pool.pool.Len()
+}
+
+func (pool *TTLPoolOf[T]) BufferCap() uint32 {
+	return pool.pool.BufferCap()
+}
+
+// Total 获取缓冲池中数据的总数 包括已过期但还没有被清理的数据
+func (pool *TTLPoolOf[T]) Total() uint64 {
+	return pool.pool.Total()
+}
+
+// PutWithTTL 阻塞地放入数据 数据在ttl之后过期 ttl为0时永不过期
+func (pool *TTLPoolOf[T]) PutWithTTL(data T, ttl time.Duration) (ok bool, err error) {
+	return pool.pool.Put(pool.stamp(data, ttl))
+}
+
+// PutWithTTLContext 阻塞地放入数据 数据在ttl之后过期 ctx 结束时返回ctx.Err()
+func (pool *TTLPoolOf[T]) PutWithTTLContext(ctx context.Context, data T, ttl time.Duration) (ok bool, err error) {
+	return pool.pool.PutContext(ctx, pool.stamp(data, ttl))
+}
+
+func (pool *TTLPoolOf[T]) Put(data T) (ok bool, err error) {
+	return pool.pool.Put(pool.stamp(data, pool.defaultTTL))
+}
+
+func (pool *TTLPoolOf[T]) TryPut(data T) (ok bool, err error) {
+	return pool.pool.TryPut(pool.stamp(data, pool.defaultTTL))
+}
+
+func (pool *TTLPoolOf[T]) PutContext(ctx context.Context, data T) (ok bool, err error) {
+	return pool.pool.PutContext(ctx, pool.stamp(data, pool.defaultTTL))
+}
+
+func (pool *TTLPoolOf[T]) PutTimeout(data T, timeout time.Duration) (ok bool, err error) {
+	return pool.pool.PutTimeout(pool.stamp(data, pool.defaultTTL), timeout)
+}
+
+func (pool *TTLPoolOf[T]) PutBatch(items []T) (n int, err error) {
+	stamped := make([]TTLItem[T], len(items))
+	for i, data := range items {
+		stamped[i] = pool.stamp(data, pool.defaultTTL)
+	}
+	return pool.pool.PutBatch(stamped)
+}
+
+// Get 阻塞地获取一个未过期的数据 取到的过期数据被丢弃
+func (pool *TTLPoolOf[T]) Get() (data T, err error) {
+	return pool.GetContext(context.Background())
+}
+
+// GetContext 阻塞地获取一个未过期的数据 ctx 结束时返回ctx.Err()
+func (pool *TTLPoolOf[T]) GetContext(ctx context.Context) (data T, err error) {
+	for {
+		item, err := pool.pool.GetContext(ctx)
+		if err != nil {
+			return data, err
+		}
+		if !item.Expired(time.Now()) {
+			return item.Value, nil
+		}
+		pool.expire(item)
+	}
+}
+
+func (pool *TTLPoolOf[T]) GetTimeout(timeout time.Duration) (data T, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return pool.GetContext(ctx)
+}
+
+// TryGet 非阻塞地获取一个未过期的数据 只剩过期数据时丢弃它们并返回ErrBufferEmpty
+func (pool *TTLPoolOf[T]) TryGet() (data T, err error) {
+	for {
+		item, err := pool.pool.TryGet()
+		if err != nil {
+			return data, err
+		}
+		if !item.Expired(time.Now()) {
+			return item.Value, nil
+		}
+		pool.expire(item)
+	}
+}
+
+// GetBatch 非阻塞地批量获取最多max个未过期的数据 取到的过期数据被丢弃
+func (pool *TTLPoolOf[T]) GetBatch(max int) (items []T, err error) {
+	for len(items) < max {
+		got, err := pool.pool.GetBatch(max - len(items))
+		if err != nil {
+			if len(items) > 0 {
+				return items, nil
+			}
+			return nil, err
+		}
+		now := time.Now()
+		for _, item := range got {
+			if item.Expired(now) {
+				pool.expire(item)
+			} else {
+				items = append(items, item.Value)
+			}
+		}
+	}
+	return items, nil
+}
+
+// Peek 查看下一个未过期的数据但不取出
+func (pool *TTLPoolOf[T]) Peek() (data T, err error) {
+	items, err := pool.PeekN(1)
+	if err != nil {
+		return data, err
+	}
+	return items[0], nil
+}
+
+// PeekN 查看最多n个未过期的数据但不取出 过期的数据跳过但不丢弃 顺序和一致性同BufferPoolOf.PeekN
+// 查看到的数据中有过期的时 加倍查看的数量重新查看 直到凑够n个或者缓冲池中没有更多的数据
+func (pool *TTLPoolOf[T]) PeekN(n int) (items []T, err error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	now := time.Now()
+	for max := n; ; max *= 2 {
+		got, err := pool.pool.PeekN(max)
+		if err != nil {
+			return nil, err
+		}
+		items = items[:0]
+		for _, item := range got {
+			if item.Expired(now) {
+				continue
+			}
+			if items = append(items, item.Value); len(items) == n {
+				return items, nil
+			}
+		}
+		if len(got) < max {
+			break
+		}
+	}
+	if len(items) == 0 {
+		return nil, ErrBufferEmpty
+	}
+	return items, nil
+}
+
+// Range 依次查看所有未过期的数据 fn返回false时停止 一致性同BufferPoolOf.Range
+func (pool *TTLPoolOf[T]) Range(fn func(data T) bool) {
+	now := time.Now()
+	pool.pool.Range(func(item TTLItem[T]) bool {
+		return item.Expired(now) || fn(item.Value)
+	})
+}
+
+// Stats 获取缓冲池的运行统计快照 Gets 包括 Get 时丢弃的过期数据
+func (pool *TTLPoolOf[T]) Stats() PoolStats {
+	stats := pool.pool.Stats()
+	stats.Expired = pool.Expired()
+	return stats
+}
+
+// Close 关闭缓冲池并结束清理协程
+func (pool *TTLPoolOf[T]) Close() bool {
+	if !pool.pool.Close() {
+		return false
+	}
+	close(pool.stop)
+	return true
+}
+
+func (pool *TTLPoolOf[T]) Closed() bool {
+	return pool.pool.Closed()
+}
+
+// Done 返回缓冲池关闭时被关闭的通道
+func (pool *TTLPoolOf[T]) Done() <-chan struct{} {
+	return pool.pool.Done()
+}
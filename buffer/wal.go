@@ -0,0 +1,650 @@
+package buffer
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SyncPolicy WAL的刷盘策略
+type SyncPolicy int32
+
+const (
+	// SyncAlways 每次放入和获取后立即刷盘 最安全也最慢 默认策略
+	SyncAlways SyncPolicy = iota
+	// SyncBatch 每累计SyncBatch次写入刷盘一次 系统崩溃时可能丢失最近还没有刷盘的放入和获取
+	SyncBatch
+	// SyncInterval 后台协程每隔SyncInterval刷盘一次
+	SyncInterval
+)
+
+var syncPolicyNames = [...]string{"always", "batch", "interval"}
+
+func (p SyncPolicy) String() string {
+	if p < 0 || int(p) >= len(syncPolicyNames) {
+		return "unknown"
+	}
+	return syncPolicyNames[p]
+}
+
+const (
+	// walCursorFile 保存读取位置的文件名
+	walCursorFile = "cursor"
+	// walCursorSize 读取位置的大小: 8字节分段序号 + 8字节偏移 + 4字节crc32
+	walCursorSize = 20
+	// defaultWALSyncBatch 默认的批量刷盘次数
+	defaultWALSyncBatch = 100
+	// defaultWALSyncInterval 默认的定时刷盘间隔
+	defaultWALSyncInterval = time.Second
+)
+
+// WALConfig 持久化缓冲池的WAL配置 零值表示使用默认值
+type WALConfig struct {
+	// SegmentSize 单个分段文件的最大字节数 为0时使用默认值64M
+	SegmentSize int64
+	// Sync 刷盘策略 默认为SyncAlways
+	Sync SyncPolicy
+	// SyncBatch SyncBatch策略下累计多少次写入刷盘一次 为0时使用默认值100
+	SyncBatch int
+	// SyncInterval SyncInterval策略下的刷盘间隔 为0时使用默认值1秒
+	SyncInterval time.Duration
+}
+
+// WALPool 存放interface{}数据的持久化缓冲池
+type WALPool = WALPoolOf[interface{}]
+
+// WALPoolOf 持久化缓冲池 实现IPoolOf接口
+// 放入的数据先追加到分段的预写日志(WAL) 再放入内存中先进先出的缓冲池 获取后持久化读取位置
+// 分段写满后新建分段 读取位置越过的分段被删除
+// 进程重启后用同一目录打开 还没有被获取的数据按放入的顺序重新放入内存
+// 内存放不下的数据留在WAL中 Get 腾出空间后自动放入 期间 Put 等待
+// 正在被获取还没有持久化读取位置的数据在重启后会再次出现 即至少一次
+type WALPoolOf[T any] struct {
+	// pool 代表存放数据的内存缓冲池
+	pool *BufferPoolOf[T]
+	// dir 代表分段文件所在的目录
+	dir string
+	// codec 代表数据的编解码器
+	codec ICodec[T]
+	// config 代表WAL配置
+	config WALConfig
+
+	// writeLock 串行化追加和加载 保证WAL中记录的顺序和内存中数据的顺序一致
+	writeLock sync.Mutex
+	// lock 保护下面所有字段 放入内存时一直持有 保证ends和内存中的数据一一对应
+	lock sync.Mutex
+	// writeSeg writeOff writeFile 代表当前写入的分段和位置
+	writeSeg  uint64
+	writeOff  int64
+	writeFile *os.File
+	// readSeg readOff 代表持久化的读取位置 之前的记录都已被获取
+	readSeg uint64
+	readOff int64
+	// cursorFile 代表保存读取位置的文件
+	cursorFile *os.File
+	// loadSeg loadOff 代表下一条还没有放入内存的记录的位置
+	loadSeg uint64
+	loadOff int64
+	// loadFile loadFileSeg 代表最近打开用于加载的分段文件
+	loadFile    *os.File
+	loadFileSeg uint64
+	// pending 代表在WAL中但还没有放入内存的记录数量
+	pending uint64
+	// ends 代表内存中的数据对应的记录结束后的位置 按放入的顺序
+	ends []spillOffset
+	// unsynced 代表上次刷盘之后的写入次数
+	unsynced int
+	// writeDirty cursorDirty 代表分段文件和读取位置是否有还没有刷盘的写入
+	writeDirty  bool
+	cursorDirty bool
+	// err 代表最近一次获取后持久化读取位置或者后台刷盘遇到的错误
+	err error
+	// closed 代表是否已关闭
+	closed bool
+	// stop 在关闭时被关闭 用于结束刷盘协程
+	stop chan struct{}
+}
+
+// OpenWALPool 打开dir目录下存放interface{}数据的持久化缓冲池 使用GobCodec编解码
+// 参数含义同NewPool和OpenWALPoolOf
+func OpenWALPool(dir string, poolCap uint32, bufferCap uint32, config WALConfig) (*WALPool, error) {
+	return OpenWALPoolOf[interface{}](dir, poolCap, bufferCap, GobCodec[interface{}]{}, config)
+}
+
+// OpenWALPoolOf 打开dir目录下存放T类型数据的持久化缓冲池 目录不存在时自动创建
+// poolCap bufferCap opts 用于创建内存缓冲池 含义同NewPoolWithOptions 内存缓冲池总是先进先出的 溢出策略不起作用
+// 打开时校验未获取的记录 截掉最后一个分段末尾写了一半的记录 再把未获取的数据放入内存
+func OpenWALPoolOf[T any](dir string, poolCap uint32, bufferCap uint32, codec ICodec[T], config WALConfig,
+	opts ...PoolOption[T]) (*WALPoolOf[T], error) {
+	if codec == nil {
+		return nil, errors.New("wal codec is nil")
+	}
+	if config.Sync < SyncAlways || config.Sync > SyncInterval || config.SyncBatch < 0 || config.SyncInterval < 0 {
+		errMsg := fmt.Sprintf("invalid params wal sync(%v) syncBatch(%d) syncInterval(%v)",
+			config.Sync, config.SyncBatch, config.SyncInterval)
+		return nil, errors.New(errMsg)
+	}
+	if config.SegmentSize <= 0 {
+		config.SegmentSize = defaultSpillSegmentSize
+	}
+	if config.SyncBatch == 0 {
+		config.SyncBatch = defaultWALSyncBatch
+	}
+	if config.SyncInterval == 0 {
+		config.SyncInterval = defaultWALSyncInterval
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	pool, err := NewPoolWithOptions[T](poolCap, bufferCap, append(opts[:len(opts):len(opts)], WithOrdered[T]())...)
+	if err != nil {
+		return nil, err
+	}
+
+	wal := &WALPoolOf[T]{pool: pool, dir: dir, codec: codec, config: config, stop: make(chan struct{})}
+	if err = wal.recover(); err != nil {
+		pool.Close()
+		wal.closeFiles()
+		return nil, err
+	}
+	wal.writeLock.Lock()
+	err = wal.load()
+	wal.writeLock.Unlock()
+	if err != nil {
+		pool.Close()
+		wal.closeFiles()
+		return nil, err
+	}
+	if config.Sync == SyncInterval {
+		go wal.syncLoop()
+	}
+	return wal, nil
+}
+
+// recover 打开读取位置 删除已读完的分段 统计未获取的记录数量 并打开最后一个分段用于追加
+func (wal *WALPoolOf[T]) recover() (err error) {
+	segs, err := listSegments(wal.dir)
+	if err != nil {
+		return err
+	}
+	if len(segs) == 0 {
+		segs = append(segs, 0)
+	}
+	if wal.cursorFile, err = os.OpenFile(filepath.Join(wal.dir, walCursorFile), os.O_CREATE|os.O_RDWR, 0644); err != nil {
+		return err
+	}
+	wal.readSeg, wal.readOff = segs[0], 0
+	if seg, off, ok := wal.loadCursor(); ok && seg >= segs[0] {
+		//读取位置所在的分段已不存在 说明之前的记录都已读完
+		if _, err = os.Stat(segmentPath(wal.dir, seg)); err != nil {
+			off = 0
+		}
+		wal.readSeg, wal.readOff = seg, off
+	}
+	for _, seg := range segs {
+		if seg < wal.readSeg {
+			os.Remove(segmentPath(wal.dir, seg))
+		}
+	}
+
+	last := segs[len(segs)-1]
+	if last < wal.readSeg {
+		last = wal.readSeg
+	}
+	for seg := wal.readSeg; seg <= last; seg++ {
+		off := int64(0)
+		if seg == wal.readSeg {
+			off = wal.readOff
+		}
+		end, n, err := scanSegment(wal.dir, seg, off)
+		if err != nil {
+			return err
+		}
+		wal.pending += n
+		if seg == last {
+			//最后一个分段末尾可能有写了一半的记录 截掉后继续追加
+			f, err := os.OpenFile(segmentPath(wal.dir, seg), os.O_CREATE|os.O_WRONLY, 0644)
+			if err != nil {
+				return err
+			}
+			if err = f.Truncate(end); err != nil {
+				f.Close()
+				return err
+			}
+			wal.writeSeg, wal.writeOff, wal.writeFile = seg, end, f
+		}
+	}
+	wal.loadSeg, wal.loadOff = wal.readSeg, wal.readOff
+	return nil
+}
+
+// loadCursor 读取保存的读取位置 文件为空或者已损坏时ok为false 从第一个分段开始读取
+func (wal *WALPoolOf[T]) loadCursor() (seg uint64, off int64, ok bool) {
+	var b [walCursorSize]byte
+	if _, err := wal.cursorFile.ReadAt(b[:], 0); err != nil {
+		return 0, 0, false
+	}
+	if crc32.ChecksumIEEE(b[:16]) != binary.BigEndian.Uint32(b[16:]) {
+		return 0, 0, false
+	}
+	return binary.BigEndian.Uint64(b[:]), int64(binary.BigEndian.Uint64(b[8:])), true
+}
+
+// saveCursor 原地写入读取位置 带crc32 写了一半时下次打开从第一个分段开始读取 调用方必须持有lock
+func (wal *WALPoolOf[T]) saveCursor() error {
+	var b [walCursorSize]byte
+	binary.BigEndian.PutUint64(b[:], wal.readSeg)
+	binary.BigEndian.PutUint64(b[8:], uint64(wal.readOff))
+	binary.BigEndian.PutUint32(b[16:], crc32.ChecksumIEEE(b[:16]))
+	_, err := wal.cursorFile.WriteAt(b[:], 0)
+	return err
+}
+
+// load 把WAL中还没有放入内存的记录按顺序放入内存 直到内存放不下为止
+// 无法解码的记录被跳过 调用方必须持有writeLock
+func (wal *WALPoolOf[T]) load() error {
+	wal.lock.Lock()
+	defer wal.lock.Unlock()
+	for wal.pending > 0 && !wal.closed {
+		f, err := wal.openLoad(wal.loadSeg)
+		if err != nil {
+			return err
+		}
+		payload, size, err := readRecord(f, wal.loadOff)
+		if err != nil && wal.loadSeg < wal.writeSeg {
+			//当前分段已读完或者后面的记录已损坏 转到下一个分段
+			wal.loadSeg, wal.loadOff = wal.loadSeg+1, 0
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		data, err := wal.codec.Decode(payload)
+		if err == nil {
+			if ok, _ := wal.pool.TryPut(data); !ok {
+				return nil
+			}
+			wal.ends = append(wal.ends, spillOffset{seg: wal.loadSeg, off: wal.loadOff + size})
+		}
+		wal.loadOff += size
+		wal.pending--
+	}
+	return nil
+}
+
+// openLoad 打开分段seg用于加载 已打开时直接复用 调用方必须持有lock
+func (wal *WALPoolOf[T]) openLoad(seg uint64) (*os.File, error) {
+	if wal.loadFile != nil && seg == wal.loadFileSeg {
+		return wal.loadFile, nil
+	}
+	f, err := os.Open(segmentPath(wal.dir, seg))
+	if err != nil {
+		return nil, err
+	}
+	if wal.loadFile != nil {
+		wal.loadFile.Close()
+	}
+	wal.loadFile, wal.loadFileSeg = f, seg
+	return f, nil
+}
+
+// encode 把数据编码为连续的记录 同时返回每条记录的大小
+func (wal *WALPoolOf[T]) encode(items []T) (records []byte, sizes []int64, err error) {
+	sizes = make([]int64, len(items))
+	for i, data := range items {
+		payload, err := wal.codec.Encode(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		record := encodeRecord(payload)
+		records = append(records, record...)
+		sizes[i] = int64(len(record))
+	}
+	return records, sizes, nil
+}
+
+// tryAppend 非阻塞地把记录追加到WAL并把数据放入内存 返回放入的数量
+// 内存放不下的数据的记录被截掉 返回ErrBufferOverload 还有数据没有放入内存时也返回ErrBufferOverload
+func (wal *WALPoolOf[T]) tryAppend(items []T, records []byte, sizes []int64) (n int, err error) {
+	wal.writeLock.Lock()
+	defer wal.writeLock.Unlock()
+	if wal.pending > 0 {
+		if err = wal.load(); err != nil {
+			return 0, err
+		}
+	}
+
+	wal.lock.Lock()
+	defer wal.lock.Unlock()
+	if wal.closed {
+		return 0, ErrClosedBufferPool
+	}
+	if wal.pending > 0 {
+		return 0, ErrBufferOverload
+	}
+	if wal.writeOff >= wal.config.SegmentSize {
+		if err = wal.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	start := wal.writeOff
+	if _, err = wal.writeFile.WriteAt(records, start); err != nil {
+		wal.writeFile.Truncate(start)
+		return 0, err
+	}
+	n, err = wal.pool.PutBatch(items)
+
+	end := start
+	for _, size := range sizes[:n] {
+		end += size
+		wal.ends = append(wal.ends, spillOffset{seg: wal.writeSeg, off: end})
+	}
+	if n < len(items) {
+		wal.writeFile.Truncate(end)
+	}
+	wal.writeOff = end
+	wal.loadSeg, wal.loadOff = wal.writeSeg, end
+	if n > 0 {
+		wal.writeDirty = true
+		if e := wal.written(n); e != nil && err == nil {
+			err = e
+		}
+	}
+	return n, err
+}
+
+// rotate 刷盘并关闭当前写入的分段 新建下一个分段 调用方必须持有lock
+func (wal *WALPoolOf[T]) rotate() error {
+	f, err := os.OpenFile(segmentPath(wal.dir, wal.writeSeg+1), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if err = wal.writeFile.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	wal.writeFile.Close()
+	wal.writeSeg, wal.writeOff, wal.writeFile = wal.writeSeg+1, 0, f
+	return nil
+}
+
+// consumed 从内存获取n个数据后 把读取位置移动到第n条记录之后 删除已读完的分段并保存读取位置
+// 之后把WAL中还没有放入内存的数据放入腾出的空间
+func (wal *WALPoolOf[T]) consumed(n int) {
+	wal.lock.Lock()
+	if wal.closed || n == 0 || n > len(wal.ends) {
+		wal.lock.Unlock()
+		return
+	}
+	to := wal.ends[n-1]
+	wal.ends = wal.ends[n:]
+	for ; wal.readSeg < to.seg; wal.readSeg++ {
+		os.Remove(segmentPath(wal.dir, wal.readSeg))
+	}
+	wal.readOff = to.off
+	err := wal.saveCursor()
+	if err == nil {
+		wal.cursorDirty = true
+		err = wal.written(n)
+	}
+	if err != nil {
+		wal.err = err
+	}
+	pending := wal.pending
+	wal.lock.Unlock()
+
+	if pending > 0 {
+		wal.writeLock.Lock()
+		if err = wal.load(); err != nil {
+			wal.lock.Lock()
+			wal.err = err
+			wal.lock.Unlock()
+		}
+		wal.writeLock.Unlock()
+	}
+}
+
+// written 按刷盘策略在n次写入后刷盘 调用方必须持有lock
+func (wal *WALPoolOf[T]) written(n int) error {
+	wal.unsynced += n
+	switch wal.config.Sync {
+	case SyncAlways:
+		return wal.sync()
+	case SyncBatch:
+		if wal.unsynced >= wal.config.SyncBatch {
+			return wal.sync()
+		}
+	}
+	return nil
+}
+
+// sync 把分段文件和读取位置刷盘 调用方必须持有lock
+func (wal *WALPoolOf[T]) sync() error {
+	wal.unsynced = 0
+	if wal.writeDirty {
+		if err := wal.writeFile.Sync(); err != nil {
+			return err
+		}
+		wal.writeDirty = false
+	}
+	if wal.cursorDirty {
+		if err := wal.cursorFile.Sync(); err != nil {
+			return err
+		}
+		wal.cursorDirty = false
+	}
+	return nil
+}
+
+// syncLoop 定时刷盘 直到关闭
+func (wal *WALPoolOf[T]) syncLoop() {
+	ticker := time.NewTicker(wal.config.SyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			wal.lock.Lock()
+			if !wal.closed {
+				if err := wal.sync(); err != nil {
+					wal.err = err
+				}
+			}
+			wal.lock.Unlock()
+		case <-wal.stop:
+			return
+		}
+	}
+}
+
+// Sync 立即把还没有刷盘的写入刷盘
+func (wal *WALPoolOf[T]) Sync() error {
+	wal.lock.Lock()
+	defer wal.lock.Unlock()
+	if wal.closed {
+		return ErrClosedBufferPool
+	}
+	return wal.sync()
+}
+
+// Err 获取最近一次获取后保存读取位置 加载数据或者后台刷盘遇到的错误
+// 这些错误不影响已经取到的数据 出错后读取位置可能落后 重启后部分数据会再次出现
+func (wal *WALPoolOf[T]) Err() error {
+	wal.lock.Lock()
+	defer wal.lock.Unlock()
+	return wal.err
+}
+
+// Pending 获取在WAL中但还没有放入内存的数据数量
+func (wal *WALPoolOf[T]) Pending() uint64 {
+	wal.lock.Lock()
+	defer wal.lock.Unlock()
+	return wal.pending
+}
+
+var walFmtMsg = "cap(%d) len(%d) bufCap(%d) total(%d) pending(%d) sync(%v)"
+
+func (wal *WALPoolOf[T]) String() string {
+	return fmt.Sprintf(walFmtMsg, wal.Cap(), wal.Len(), wal.BufferCap(), wal.pool.Total(), wal.Pending(), wal.config.Sync)
+}
+
+func (wal *WALPoolOf[T]) Cap() uint32 {
+	return wal.pool.Cap()
+}
+
+func (wal *WALPoolOf[T]) Len() uint32 {
+	return wal.pool.Len()
+}
+
+func (wal *WALPoolOf[T]) BufferCap() uint32 {
+	return wal.pool.BufferCap()
+}
+
+// Total 获取数据的总数 包括还没有放入内存的数据
+func (wal *WALPoolOf[T]) Total() uint64 {
+	return wal.pool.Total() + wal.Pending()
+}
+
+func (wal *WALPoolOf[T]) Put(data T) (ok bool, err error) {
+	return wal.PutContext(context.Background(), data)
+}
+
+// TryPut 非阻塞地放入数据 追加到WAL并放入内存后返回 刷盘失败时ok为true并返回错误
+func (wal *WALPoolOf[T]) TryPut(data T) (ok bool, err error) {
+	records, sizes, err := wal.encode([]T{data})
+	if err != nil {
+		return false, err
+	}
+	n, err := wal.tryAppend([]T{data}, records, sizes)
+	return n == 1, err
+}
+
+// PutContext 阻塞地放入数据 内存已满时等待 Get 腾出空间 ctx 结束时返回ctx.Err()
+func (wal *WALPoolOf[T]) PutContext(ctx context.Context, data T) (ok bool, err error) {
+	items := []T{data}
+	records, sizes, err := wal.encode(items)
+	if err != nil {
+		return false, err
+	}
+	err = wal.pool.waitPut(ctx, nil, nil, func() (bool, error) {
+		var n int
+		n, err = wal.tryAppend(items, records, sizes)
+		ok = n == 1
+		return err != ErrBufferOverload, err
+	})
+	return
+}
+
+func (wal *WALPoolOf[T]) PutTimeout(data T, timeout time.Duration) (ok bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return wal.PutContext(ctx, data)
+}
+
+// PutBatch 非阻塞地批量放入数据 一次追加到WAL 只保留放入内存的数据的记录
+func (wal *WALPoolOf[T]) PutBatch(items []T) (n int, err error) {
+	if len(items) == 0 {
+		return 0, nil
+	}
+	records, sizes, err := wal.encode(items)
+	if err != nil {
+		return 0, err
+	}
+	return wal.tryAppend(items, records, sizes)
+}
+
+func (wal *WALPoolOf[T]) Get() (data T, err error) {
+	return wal.GetContext(context.Background())
+}
+
+// GetContext 阻塞地获取数据 取到后持久化读取位置 ctx 结束时返回ctx.Err()
+func (wal *WALPoolOf[T]) GetContext(ctx context.Context) (data T, err error) {
+	if data, err = wal.pool.GetContext(ctx); err == nil {
+		wal.consumed(1)
+	}
+	return
+}
+
+func (wal *WALPoolOf[T]) GetTimeout(timeout time.Duration) (data T, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return wal.GetContext(ctx)
+}
+
+func (wal *WALPoolOf[T]) TryGet() (data T, err error) {
+	if data, err = wal.pool.TryGet(); err == nil {
+		wal.consumed(1)
+	}
+	return
+}
+
+func (wal *WALPoolOf[T]) GetBatch(max int) (items []T, err error) {
+	if items, err = wal.pool.GetBatch(max); len(items) > 0 {
+		wal.consumed(len(items))
+	}
+	return
+}
+
+// Peek 查看下一个数据但不取出 只查看内存中的数据
+func (wal *WALPoolOf[T]) Peek() (data T, err error) {
+	return wal.pool.Peek()
+}
+
+// PeekN 按放入的顺序查看内存中最多n个数据但不取出 还没有放入内存的数据不在其中
+func (wal *WALPoolOf[T]) PeekN(n int) (items []T, err error) {
+	return wal.pool.PeekN(n)
+}
+
+// Range 按放入的顺序依次查看内存中的数据 fn返回false时停止
+func (wal *WALPoolOf[T]) Range(fn func(data T) bool) {
+	wal.pool.Range(fn)
+}
+
+// Stats 获取缓冲池的运行统计快照 Total 包括还没有放入内存的数据
+func (wal *WALPoolOf[T]) Stats() PoolStats {
+	stats := wal.pool.Stats()
+	stats.Total += wal.Pending()
+	return stats
+}
+
+// Close 刷盘并关闭缓冲池 内存中的数据丢弃 WAL中未获取的数据保留在磁盘上 下次打开时继续获取
+func (wal *WALPoolOf[T]) Close() bool {
+	wal.writeLock.Lock()
+	defer wal.writeLock.Unlock()
+	wal.lock.Lock()
+	defer wal.lock.Unlock()
+	if wal.closed {
+		return false
+	}
+	wal.closed = true
+	close(wal.stop)
+	wal.pool.Close()
+	if err := wal.sync(); err != nil {
+		wal.err = err
+	}
+	wal.closeFiles()
+	return true
+}
+
+// closeFiles 关闭所有打开的文件
+func (wal *WALPoolOf[T]) closeFiles() {
+	for _, f := range []*os.File{wal.writeFile, wal.cursorFile, wal.loadFile} {
+		if f != nil {
+			f.Close()
+		}
+	}
+}
+
+func (wal *WALPoolOf[T]) Closed() bool {
+	return wal.pool.Closed()
+}
+
+// Done 返回缓冲池关闭时被关闭的通道
+func (wal *WALPoolOf[T]) Done() <-chan struct{} {
+	return wal.pool.Done()
+}
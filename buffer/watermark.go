@@ -0,0 +1,149 @@
+package buffer
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// WatermarkKind 水位线监控的对象
+type WatermarkKind int32
+
+const (
+	// WatermarkTotal 缓冲池中数据的总数
+	WatermarkTotal WatermarkKind = iota
+	// WatermarkBuffers 缓冲器的数量
+	WatermarkBuffers
+)
+
+func (k WatermarkKind) String() string {
+	if k == WatermarkBuffers {
+		return "buffers"
+	}
+	return "total"
+}
+
+// WatermarkEvent 水位线事件
+type WatermarkEvent struct {
+	// Kind 代表越过水位线的监控对象
+	Kind WatermarkKind
+	// High 为true代表升到了高水位线 为false代表降到了低水位线
+	High bool
+	// Value 代表越过水位线时监控对象的值
+	Value uint64
+}
+
+func (e WatermarkEvent) String() string {
+	if e.High {
+		return fmt.Sprintf("%v high(%d)", e.Kind, e.Value)
+	}
+	return fmt.Sprintf("%v low(%d)", e.Kind, e.Value)
+}
+
+// watermark 带滞后区间的水位线 升到high时进入高水位 降到low时才恢复
+type watermark struct {
+	high uint64
+	low  uint64
+	// state 代表是否处于高水位：0-否；1-是。
+	state uint32
+}
+
+// cross 用当前值检查是否越过水位线 changed为true时high代表新的状态
+func (w *watermark) cross(value uint64) (changed, high bool) {
+	if w == nil {
+		return false, false
+	}
+	if value >= w.high {
+		return atomic.CompareAndSwapUint32(&w.state, 0, 1), true
+	}
+	if value <= w.low {
+		return atomic.CompareAndSwapUint32(&w.state, 1, 0), false
+	}
+	return false, false
+}
+
+// isHigh 判断是否处于高水位
+func (w *watermark) isHigh() bool {
+	return w != nil && atomic.LoadUint32(&w.state) == 1
+}
+
+// newWatermark 校验参数并创建水位线
+func newWatermark(kind WatermarkKind, high, low uint64) (*watermark, error) {
+	if high == 0 || low >= high {
+		return nil, fmt.Errorf("invalid params %v watermarks: low(%d) < high(%d)", kind, low, high)
+	}
+	return &watermark{high: high, low: low}, nil
+}
+
+// watermarkChanSize 代表水位线通知通道的容量
+const watermarkChanSize = 64
+
+// WithWatermarks 设置数据总数的高低水位线 需要low < high
+// 数据总数升到high时进入暂停状态并通知 之后降到low时才恢复并通知 中间的波动不会重复通知
+func WithWatermarks[T any](high, low uint64) PoolOption[T] {
+	return func(pool *BufferPoolOf[T]) (err error) {
+		pool.totalMark, err = newWatermark(WatermarkTotal, high, low)
+		pool.initWatermarkChan()
+		return
+	}
+}
+
+// WithBufferWatermarks 设置缓冲器数量的高低水位线 需要low < high 用于监控缓冲池的增长
+func WithBufferWatermarks[T any](high, low uint32) PoolOption[T] {
+	return func(pool *BufferPoolOf[T]) (err error) {
+		pool.bufferMark, err = newWatermark(WatermarkBuffers, uint64(high), uint64(low))
+		pool.initWatermarkChan()
+		return
+	}
+}
+
+// WithWatermarkHandler 设置越过水位线时的回调函数
+// 回调在 Put Get 的协程中同步调用 应尽快返回 不要在回调中访问本缓冲池
+func WithWatermarkHandler[T any](handler func(e WatermarkEvent)) PoolOption[T] {
+	return func(pool *BufferPoolOf[T]) error {
+		pool.watermarkFn = handler
+		return nil
+	}
+}
+
+// initWatermarkChan 创建水位线通知通道
+func (pool *BufferPoolOf[T]) initWatermarkChan() {
+	if pool.watermarkCh == nil {
+		pool.watermarkCh = make(chan WatermarkEvent, watermarkChanSize)
+	}
+}
+
+// Watermarks 返回水位线通知通道 没有设置水位线时为nil
+// 通道已满时新的事件被丢弃 当前状态以Paused为准
+func (pool *BufferPoolOf[T]) Watermarks() <-chan WatermarkEvent {
+	return pool.watermarkCh
+}
+
+// Paused 判断缓冲池是否处于高水位 上游生产者可以据此暂停
+func (pool *BufferPoolOf[T]) Paused() bool {
+	return pool.totalMark.isHigh() || pool.bufferMark.isHigh()
+}
+
+// checkTotal 用当前的数据总数检查水位线
+func (pool *BufferPoolOf[T]) checkTotal(total uint64) {
+	if changed, high := pool.totalMark.cross(total); changed {
+		pool.notifyWatermark(WatermarkEvent{Kind: WatermarkTotal, High: high, Value: total})
+	}
+}
+
+// checkBuffers 用当前的缓冲器数量检查水位线
+func (pool *BufferPoolOf[T]) checkBuffers(size uint32) {
+	if changed, high := pool.bufferMark.cross(uint64(size)); changed {
+		pool.notifyWatermark(WatermarkEvent{Kind: WatermarkBuffers, High: high, Value: uint64(size)})
+	}
+}
+
+// notifyWatermark 调用回调函数并发送到通知通道
+func (pool *BufferPoolOf[T]) notifyWatermark(e WatermarkEvent) {
+	if pool.watermarkFn != nil {
+		pool.watermarkFn(e)
+	}
+	select {
+	case pool.watermarkCh <- e:
+	default:
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"buffer"
+	"flag"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+func init() {
+	glog.MaxSize = 1024 * 1024 * 100    //最大100M
+	flag.Set("alsologtostderr", "true") // 日志写入文件的同时，输出到stderr
+	flag.Set("log_dir", "./log")        // 日志文件保存目录
+	flag.Set("v", "1")                  // 配置V输出的等级。
+	flag.Parse()
+}
+
+const (
+	poolCap    = 2
+	bufferCap  = 4
+	visibility = 50 * time.Millisecond
+)
+
+// testAckNack Nack 后数据重新投递 旧的编号失效 Ack 后数据删除
+func testAckNack() {
+	pool, err := buffer.NewAckPoolOf[int](poolCap, bufferCap, time.Hour, 0, nil)
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	defer pool.Close()
+
+	pool.Put(1)
+	d, err := pool.TryGet()
+	if err != nil || d.Value != 1 || d.Deliveries != 1 || pool.InFlight() != 1 {
+		glog.Errorf("first delivery:%+v err:%v inflight:%d", d, err, pool.InFlight())
+		return
+	}
+	if err = d.Nack(); err != nil || pool.Redelivered() != 1 || pool.InFlight() != 0 {
+		glog.Errorf("nack err:%v redelivered:%d inflight:%d", err, pool.Redelivered(), pool.InFlight())
+		return
+	}
+	d2, err := pool.TryGet()
+	if err != nil || d2.Value != 1 || d2.Deliveries != 2 || d2.ID == d.ID {
+		glog.Errorf("redelivery:%+v err:%v", d2, err)
+		return
+	}
+	if err = d.Ack(); err != buffer.ErrUnknownDelivery {
+		glog.Errorf("ack old delivery err:%v want ErrUnknownDelivery", err)
+		return
+	}
+	if err = d2.Ack(); err != nil || pool.Acked() != 1 || pool.InFlight() != 0 {
+		glog.Errorf("ack err:%v acked:%d inflight:%d", err, pool.Acked(), pool.InFlight())
+		return
+	}
+	if err = d2.Ack(); err != buffer.ErrUnknownDelivery {
+		glog.Errorf("ack twice err:%v want ErrUnknownDelivery", err)
+		return
+	}
+	if _, err = pool.TryGet(); err != buffer.ErrBufferEmpty {
+		glog.Errorf("get after ack err:%v want ErrBufferEmpty", err)
+		return
+	}
+	glog.Infof("ack nack ok %v", pool)
+}
+
+// testVisibility 超过visibility没有确认的数据重新投递
+func testVisibility() {
+	pool, err := buffer.NewAckPoolOf[int](poolCap, bufferCap, visibility, 0, nil)
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	defer pool.Close()
+
+	pool.Put(2)
+	d, err := pool.TryGet()
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	d2, err := pool.GetTimeout(20 * visibility)
+	if err != nil || d2.Value != 2 || d2.Deliveries != 2 || pool.Redelivered() != 1 {
+		glog.Errorf("redelivery after visibility:%+v err:%v redelivered:%d", d2, err, pool.Redelivered())
+		return
+	}
+	if err = d.Ack(); err != buffer.ErrUnknownDelivery {
+		glog.Errorf("ack expired delivery err:%v want ErrUnknownDelivery", err)
+		return
+	}
+	if err = d2.Ack(); err != nil {
+		glog.Error(err)
+		return
+	}
+	glog.Infof("visibility ok %v", pool)
+}
+
+// testDeadLetter 投递次数达到maxDeliveries的数据放入死信缓冲池 不再投递
+func testDeadLetter() {
+	deadLetter, err := buffer.NewPoolOf[int](1, 1)
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	defer deadLetter.Close()
+	pool, err := buffer.NewAckPoolOf[int](poolCap, bufferCap, time.Hour, 2, deadLetter)
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	defer pool.Close()
+
+	pool.Put(3)
+	for i := 0; i < 2; i++ {
+		d, err := pool.TryGet()
+		if err != nil {
+			glog.Errorf("delivery %d err:%v", i+1, err)
+			return
+		}
+		d.Nack()
+	}
+	if _, err = pool.TryGet(); err != buffer.ErrBufferEmpty {
+		glog.Errorf("get after dead letter err:%v want ErrBufferEmpty", err)
+		return
+	}
+	data, err := deadLetter.TryGet()
+	if err != nil || data != 3 || pool.DeadLettered() != 1 || pool.Redelivered() != 1 {
+		glog.Errorf("dead letter:%d err:%v deadLettered:%d redelivered:%d",
+			data, err, pool.DeadLettered(), pool.Redelivered())
+		return
+	}
+	glog.Infof("dead letter ok %v", pool)
+}
+
+// testDeadLetterClosed 死信缓冲池已关闭时数据保留在投递中 Err返回错误
+func testDeadLetterClosed() {
+	deadLetter, err := buffer.NewPoolOf[int](1, 1)
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	deadLetter.Close()
+	pool, err := buffer.NewAckPoolOf[int](poolCap, bufferCap, time.Hour, 1, deadLetter)
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	defer pool.Close()
+
+	pool.Put(4)
+	d, err := pool.TryGet()
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	d.Nack()
+	if pool.Err() != buffer.ErrClosedBufferPool || pool.DeadLettered() != 0 || pool.InFlight() != 1 {
+		glog.Errorf("err:%v deadLettered:%d inflight:%d", pool.Err(), pool.DeadLettered(), pool.InFlight())
+		return
+	}
+	glog.Infof("dead letter closed ok err:%v", pool.Err())
+}
+
+func main() {
+	defer glog.Flush()
+	testAckNack()
+	testVisibility()
+	testDeadLetter()
+	testDeadLetterClosed()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"buffer"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+func init() {
+	glog.MaxSize = 1024 * 1024 * 100    //最大100M
+	flag.Set("alsologtostderr", "true") // 日志写入文件的同时，输出到stderr
+	flag.Set("log_dir", "./log")        // 日志文件保存目录
+	flag.Set("v", "1")                  // 配置V输出的等级。
+	flag.Parse()
+}
+
+const (
+	poolCap   = 1
+	bufferCap = 4
+	total     = 10
+	delay     = 10 * time.Millisecond
+)
+
+// putDue 放入total个延时delay的数据 等到全部到期
+func putDue(queue *buffer.DelayQueueOf[int]) {
+	for i := 0; i < total; i++ {
+		queue.PutAfter(i, delay)
+	}
+	time.Sleep(delay * 5)
+}
+
+// testBlock 默认策略下缓冲池已满时 到期的数据留在堆中计入Delayed 取走后按顺序放入
+func testBlock() {
+	queue, err := buffer.NewDelayQueueOf[int](poolCap, bufferCap)
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	defer queue.Close()
+
+	putDue(queue)
+	if queue.Total() != bufferCap || queue.Delayed() != total-bufferCap {
+		glog.Errorf("block full: %v want total(%d) delayed(%d)", queue, bufferCap, total-bufferCap)
+		return
+	}
+	var got []int
+	for i := 0; i < total; i++ {
+		data, err := queue.GetTimeout(time.Second)
+		if err != nil {
+			glog.Errorf("block get %d err:%v", i, err)
+			return
+		}
+		got = append(got, data)
+	}
+	if fmt.Sprint(got) != fmt.Sprint([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}) || queue.Delayed() != 0 {
+		glog.Errorf("block got %v %v", got, queue)
+		return
+	}
+	glog.Infof("block got %v %v", got, queue)
+}
+
+// testDropNewest OverflowDropNewest策略下放不下的到期数据被丢弃 调度协程继续工作
+func testDropNewest() {
+	queue, err := buffer.NewDelayQueueOf[int](poolCap, bufferCap,
+		buffer.WithOverflowPolicy[int](buffer.OverflowDropNewest, nil))
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	defer queue.Close()
+
+	putDue(queue)
+	if queue.Total() != bufferCap || queue.Delayed() != 0 || queue.Dropped() != total-bufferCap {
+		glog.Errorf("drop full: %v dropped(%d) want dropped(%d)", queue, queue.Dropped(), total-bufferCap)
+		return
+	}
+	got, _ := queue.GetBatch(total)
+	queue.PutAfter(total, delay)
+	data, err := queue.GetTimeout(time.Second)
+	if err != nil || data != total {
+		glog.Errorf("drop after full get %d err:%v", data, err)
+		return
+	}
+	glog.Infof("drop got %v then %d dropped(%d) %v", got, data, queue.Dropped(), queue)
+}
+
+func main() {
+	defer glog.Flush()
+	testBlock()
+	testDropNewest()
+}
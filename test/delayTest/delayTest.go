@@ -0,0 +1,57 @@
+package main
+
+import (
+	"buffer"
+	"flag"
+	"math/rand"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+func init() {
+	glog.MaxSize = 1024 * 1024 * 100    //最大100M
+	flag.Set("alsologtostderr", "true") // 日志写入文件的同时，输出到stderr
+	flag.Set("log_dir", "./log")        // 日志文件保存目录
+	flag.Set("v", "1")                  // 配置V输出的等级。
+	flag.Parse()
+}
+
+const total = 100000
+
+// 放入total个随机延时的数据 检查每个数据都不早于到期时间取到 并统计延后的时间
+func main() {
+	queue, err := buffer.NewDelayQueueOf[time.Time](10, 4096)
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	defer queue.Close()
+
+	now := time.Now()
+	for i := 0; i < total; i++ {
+		at := now.Add(time.Duration(rand.Intn(2000)) * time.Millisecond)
+		queue.PutAt(at, at)
+	}
+	glog.Infof("put %d %v", total, queue)
+
+	var early int
+	var maxLate, sumLate time.Duration
+	for i := 0; i < total; i++ {
+		at, err := queue.GetTimeout(time.Second * 5)
+		if err != nil {
+			glog.Errorf("get %d err:%v", i, err)
+			return
+		}
+		late := time.Now().Sub(at)
+		if late < 0 {
+			early++
+		}
+		if late > maxLate {
+			maxLate = late
+		}
+		sumLate += late
+	}
+	glog.Infof("get %d early(%d) maxLate(%v) avgLate(%v) %v", total, early, maxLate, sumLate/total, queue)
+	glog.Flush()
+}
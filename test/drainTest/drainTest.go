@@ -0,0 +1,139 @@
+package main
+
+import (
+	"buffer"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+func init() {
+	glog.MaxSize = 1024 * 1024 * 100    //最大100M
+	flag.Set("alsologtostderr", "true") // 日志写入文件的同时，输出到stderr
+	flag.Set("log_dir", "./log")        // 日志文件保存目录
+	flag.Set("v", "1")                  // 配置V输出的等级。
+	flag.Parse()
+}
+
+const (
+	poolCap   = 1
+	bufferCap = 2
+	total     = 5
+	timeout   = 50 * time.Millisecond
+)
+
+// waitDraining 等到缓冲池开始排空
+func waitDraining(pool *buffer.BufferPoolOf[int]) {
+	for !pool.Draining() {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// testDrainTimeout 排空时阻塞的 Put 返回ErrDrainingBufferPool 超时后返回没有取走的数据
+func testDrainTimeout() {
+	pool, err := buffer.NewPoolOf[int](poolCap, bufferCap)
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	pool.Put(0)
+	pool.Put(1)
+	blocked := make(chan error, 1)
+	go func() {
+		_, err := pool.Put(bufferCap)
+		blocked <- err
+	}()
+
+	items, err := pool.CloseWithTimeout(timeout)
+	if err != context.DeadlineExceeded || fmt.Sprint(items) != "[0 1]" {
+		glog.Errorf("drain timeout items:%v err:%v", items, err)
+		return
+	}
+	if err = <-blocked; err != buffer.ErrDrainingBufferPool {
+		glog.Errorf("blocked put during drain err:%v want %v", err, buffer.ErrDrainingBufferPool)
+		return
+	}
+	glog.Infof("drain timeout items:%v blocked put err:%v", items, err)
+}
+
+// testDrainSpill 排空时溢出日志不为空 Put 也不能写入溢出日志 Drain在内存取空后结束
+// 溢出日志中的数据保留在磁盘上 重新打开后可以读出
+func testDrainSpill() {
+	dir, err := os.MkdirTemp("", "drainTest")
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	spill, err := buffer.OpenSpillLog[int](dir, 0, buffer.GobCodec[int]{})
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	pool, err := buffer.NewPoolWithOptions[int](poolCap, bufferCap, buffer.WithSpill[int](spill))
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	for i := 0; i < total; i++ {
+		pool.Put(i)
+	}
+	spilled := pool.SpillLen()
+
+	type result struct {
+		items []int
+		err   error
+	}
+	drained := make(chan result, 1)
+	go func() {
+		items, err := pool.Drain(context.Background())
+		drained <- result{items, err}
+	}()
+	waitDraining(pool)
+
+	if ok, err := pool.Put(total); ok || err != buffer.ErrDrainingBufferPool || pool.SpillLen() != spilled {
+		glog.Errorf("put during drain ok:%v err:%v spillLen:%d want %d", ok, err, pool.SpillLen(), spilled)
+		return
+	}
+	if _, err := pool.PutBatch([]int{total}); err != buffer.ErrDrainingBufferPool {
+		glog.Errorf("put batch during drain err:%v", err)
+		return
+	}
+	var got []int
+	for {
+		data, err := pool.Get()
+		if err != nil {
+			break
+		}
+		got = append(got, data)
+	}
+	res := <-drained
+	if res.err != nil || len(res.items) != 0 || fmt.Sprint(got) != "[0 1]" {
+		glog.Errorf("drain with spill got:%v left:%v err:%v", got, res.items, res.err)
+		return
+	}
+
+	spill, err = buffer.OpenSpillLog[int](dir, 0, buffer.GobCodec[int]{})
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	defer spill.Close()
+	left, _ := spill.Read(total)
+	if fmt.Sprint(left) != "[2 3 4]" {
+		glog.Errorf("spill after drain:%v want [2 3 4]", left)
+		return
+	}
+	glog.Infof("drain with spill got:%v spill after drain:%v", got, left)
+}
+
+func main() {
+	defer glog.Flush()
+	testDrainTimeout()
+	testDrainSpill()
+}
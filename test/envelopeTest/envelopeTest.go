@@ -0,0 +1,102 @@
+package main
+
+import (
+	"buffer"
+	"flag"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+func init() {
+	glog.MaxSize = 1024 * 1024 * 100    //最大100M
+	flag.Set("alsologtostderr", "true") // 日志写入文件的同时，输出到stderr
+	flag.Set("log_dir", "./log")        // 日志文件保存目录
+	flag.Set("v", "1")                  // 配置V输出的等级。
+	flag.Parse()
+}
+
+const (
+	poolCap   = 1
+	bufferCap = 2
+	failTimes = 3
+	waitTime  = 50 * time.Millisecond
+)
+
+// testSequence 放入失败不占用编号 取出的编号是连续的
+func testSequence() {
+	pool, err := buffer.NewEnvelopePool(poolCap, bufferCap)
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	defer pool.Close()
+
+	pool.Put(1)
+	pool.Put(2)
+	for i := 0; i < failTimes; i++ {
+		if ok, err := pool.TryPut(0); ok || err != buffer.ErrBufferOverload {
+			glog.Errorf("try put to a full pool ok:%v err:%v", ok, err)
+			return
+		}
+	}
+	if ok, err := pool.PutTimeout(0, time.Millisecond); ok {
+		glog.Errorf("put timeout to a full pool ok:%v err:%v", ok, err)
+		return
+	}
+	pool.TryGet()
+	pool.TryGet()
+	pool.PutBatch([]interface{}{3, 4, 5})
+
+	var ids []uint64
+	for {
+		env, err := pool.TryGetEnvelope()
+		if err != nil {
+			break
+		}
+		ids = append(ids, env.ID)
+	}
+	if len(ids) != bufferCap || ids[0] != 3 || ids[1] != 4 {
+		glog.Errorf("ids after failed puts:%v want [3 4]", ids)
+		return
+	}
+	glog.Infof("ids after failed puts:%v %s", ids, pool)
+}
+
+// testLatency 等待放入的时间不计入排队延迟
+func testLatency() {
+	pool, err := buffer.NewEnvelopePool(poolCap, bufferCap)
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	defer pool.Close()
+
+	pool.Put(1)
+	pool.Put(2)
+	go func() {
+		time.Sleep(waitTime)
+		pool.TryGetEnvelope()
+	}()
+	start := time.Now()
+	pool.Put(3)
+	blocked := time.Since(start)
+
+	pool.TryGetEnvelope()
+	env, err := pool.TryGetEnvelope()
+	if err != nil || env.ID != 3 {
+		glog.Errorf("get blocked envelope id:%d err:%v", env.ID, err)
+		return
+	}
+	if age := env.Age(time.Now()); age >= waitTime {
+		glog.Errorf("latency %v includes the producer wait %v", age, blocked)
+		return
+	}
+	glog.Infof("producer wait:%v latency:%v", blocked, env.Age(time.Now()))
+}
+
+func main() {
+	defer glog.Flush()
+	testSequence()
+	testLatency()
+}
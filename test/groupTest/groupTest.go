@@ -0,0 +1,150 @@
+package main
+
+import (
+	"buffer"
+	"context"
+	"flag"
+	"os"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+func init() {
+	glog.MaxSize = 1024 * 1024 * 100    //最大100M
+	flag.Set("alsologtostderr", "true") // 日志写入文件的同时，输出到stderr
+	flag.Set("log_dir", "./log")        // 日志文件保存目录
+	flag.Set("v", "1")                  // 配置V输出的等级。
+	flag.Parse()
+}
+
+const (
+	poolCap   = 1
+	bufferCap = 2
+	total     = 100
+)
+
+// testAbandoned 消费组的成员都离开后 Publish 不再等待该组 放不下的数据计入该组的Dropped
+func testAbandoned() {
+	topic, err := buffer.NewGroupTopicOf[int](poolCap, bufferCap)
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	defer topic.Close()
+
+	active, _ := topic.Join("active")
+	gone, _ := topic.Join("gone")
+	gone.Leave()
+
+	consumed := make(chan struct{})
+	go func() {
+		defer close(consumed)
+		for i := 0; i < total; i++ {
+			if _, err := active.Get(); err != nil {
+				glog.Errorf("active get %d err:%v", i, err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < total; i++ {
+		if _, err := topic.Publish(i); err != nil {
+			glog.Errorf("publish %d err:%v", i, err)
+			return
+		}
+	}
+	<-consumed
+	stats, _ := topic.Group("gone")
+	if stats.Lag != bufferCap || stats.Dropped != total-bufferCap {
+		glog.Errorf("abandoned group %+v want lag(%d) dropped(%d)", stats, bufferCap, total-bufferCap)
+		return
+	}
+	glog.Infof("abandoned group lag(%d) dropped(%d)", stats.Lag, stats.Dropped)
+}
+
+// testLastLeave Publish 等待一个已满的消费组时 最后一个成员离开后 Publish 不再等待
+func testLastLeave() {
+	topic, err := buffer.NewGroupTopicOf[int](poolCap, bufferCap)
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	defer topic.Close()
+
+	member, _ := topic.Join("slow")
+	for i := 0; i < bufferCap; i++ {
+		topic.Publish(i)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := topic.Publish(bufferCap)
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	member.Leave()
+	select {
+	case err = <-done:
+	case <-time.After(time.Second):
+		glog.Error("publish still blocked after the last member left")
+		return
+	}
+	stats, _ := topic.Group("slow")
+	glog.Infof("last leave publish err:%v dropped(%d)", err, stats.Dropped)
+}
+
+// testGetLeave 阻塞在 GetContext 的成员离开时返回ErrLeftGroup
+func testGetLeave() {
+	topic, err := buffer.NewGroupTopicOf[int](poolCap, bufferCap)
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	defer topic.Close()
+
+	member, _ := topic.Join("g")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		member.Leave()
+	}()
+	if _, err = member.GetContext(ctx); err != buffer.ErrLeftGroup {
+		glog.Errorf("get after leave err:%v want %v", err, buffer.ErrLeftGroup)
+		return
+	}
+	glog.Infof("get after leave err:%v", err)
+}
+
+// testSharedSpill 一个溢出日志不能被多个消费组或者多个子缓冲池共用
+func testSharedSpill() {
+	dir, err := os.MkdirTemp("", "groupTest")
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	spill, err := buffer.OpenSpillLog[int](dir, 0, buffer.GobCodec[int]{})
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	defer spill.Close()
+
+	if _, err = buffer.NewGroupTopicOf[int](poolCap, bufferCap, buffer.WithSpill[int](spill)); err != buffer.ErrSharedSpill {
+		glog.Errorf("group topic with spill err:%v want %v", err, buffer.ErrSharedSpill)
+		return
+	}
+	if _, err = buffer.NewShardedPoolOf[int](2, poolCap, bufferCap, buffer.WithSpill[int](spill)); err != buffer.ErrSharedSpill {
+		glog.Errorf("sharded pool with spill err:%v want %v", err, buffer.ErrSharedSpill)
+		return
+	}
+	glog.Infof("shared spill err:%v", err)
+}
+
+func main() {
+	defer glog.Flush()
+	testAbandoned()
+	testLastLeave()
+	testGetLeave()
+	testSharedSpill()
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"buffer"
+	"flag"
+	"sync"
+
+	"github.com/golang/glog"
+)
+
+func init() {
+	glog.MaxSize = 1024 * 1024 * 100    //最大100M
+	flag.Set("alsologtostderr", "true") // 日志写入文件的同时，输出到stderr
+	flag.Set("log_dir", "./log")        // 日志文件保存目录
+	flag.Set("v", "1")                  // 配置V输出的等级。
+	flag.Parse()
+}
+
+// message 生产者编号和该生产者内的序号
+type message struct {
+	producer int
+	seq      int
+}
+
+const (
+	producers = 10
+	perCount  = 512000
+)
+
+// testOrder 多个生产者并发Put 一个消费者Get 检查每个生产者的序号是否严格递增
+// 返回乱序的次数
+func testOrder(pool buffer.IPoolOf[message]) (disorder int) {
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < perCount; i++ {
+				if _, err := pool.Put(message{producer: p, seq: i}); err != nil {
+					glog.Error("Put err:", err)
+					return
+				}
+			}
+		}(p)
+	}
+
+	last := make([]int, producers)
+	for i := range last {
+		last[i] = -1
+	}
+	for i := 0; i < producers*perCount; i++ {
+		msg, err := pool.Get()
+		if err != nil {
+			glog.Error("Get err:", err)
+			break
+		}
+		if msg.seq <= last[msg.producer] {
+			disorder++
+		}
+		last[msg.producer] = msg.seq
+	}
+	wg.Wait()
+	return
+}
+
+func main() {
+	ordered, _ := buffer.NewOrderedPoolOf[message](10, 4096)
+	glog.Info("ordered pool disorder:", testOrder(ordered), " ", ordered)
+	ordered.Close()
+
+	pool, _ := buffer.NewPoolOf[message](10, 4096)
+	glog.Info("normal pool disorder:", testOrder(pool), " ", pool)
+	pool.Close()
+}
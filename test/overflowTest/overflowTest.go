@@ -0,0 +1,81 @@
+package main
+
+import (
+	"buffer"
+	"flag"
+	"fmt"
+
+	"github.com/golang/glog"
+)
+
+func init() {
+	glog.MaxSize = 1024 * 1024 * 100    //最大100M
+	flag.Set("alsologtostderr", "true") // 日志写入文件的同时，输出到stderr
+	flag.Set("log_dir", "./log")        // 日志文件保存目录
+	flag.Set("v", "1")                  // 配置V输出的等级。
+	flag.Parse()
+}
+
+const (
+	poolCap   = 2
+	bufferCap = 4
+	total     = poolCap * bufferCap
+	extra     = 3
+)
+
+// testDropOldest 放满缓冲池后再放入extra个数据 检查被淘汰的数据和剩下数据的顺序
+// evicted为期望淘汰的数量 want为期望剩下的数据 按Get的顺序
+func testDropOldest(name string, pool *buffer.BufferPoolOf[int], evicted uint64, want []int) {
+	for i := 0; i < total+extra; i++ {
+		if _, err := pool.Put(i); err != nil {
+			glog.Errorf("%s Put(%d) err:%v", name, i, err)
+			return
+		}
+	}
+
+	var got []int
+	for {
+		data, err := pool.TryGet()
+		if err != nil {
+			break
+		}
+		got = append(got, data)
+	}
+	if pool.Evicted() != evicted || fmt.Sprint(got) != fmt.Sprint(want) {
+		glog.Errorf("%s evicted:%d remain:%v want evicted:%d remain:%v", name, pool.Evicted(), got, evicted, want)
+		return
+	}
+	glog.Infof("%s evicted:%d remain:%v", name, pool.Evicted(), got)
+}
+
+func main() {
+	defer glog.Flush()
+
+	//先进先出模式 放入8 9 10时依次淘汰最老的0 1 2 后面的数据前移 链尾腾出空间
+	ordered, err := buffer.NewPoolWithOptions[int](poolCap, bufferCap,
+		buffer.WithOrdered[int](), buffer.WithOverflowPolicy[int](buffer.OverflowDropOldest, nil))
+	if err != nil {
+		glog.Error("NewPoolWithOptions err:", err)
+		return
+	}
+	testDropOldest("ordered", ordered, extra, []int{3, 4, 5, 6, 7, 8, 9, 10})
+
+	//优先级模式 偶数优先级高 淘汰最低优先级中最老的1 3 5
+	priority, err := buffer.NewPoolWithOptions[int](1, total,
+		buffer.WithPriority[int](2, func(data int) int { return data % 2 }, 0),
+		buffer.WithOverflowPolicy[int](buffer.OverflowDropOldest, nil))
+	if err != nil {
+		glog.Error("NewPoolWithOptions err:", err)
+		return
+	}
+	testDropOldest("priority", priority, extra, []int{0, 2, 4, 6, 8, 10, 7, 9})
+
+	//普通模式只有一个缓冲器时 队头就是最老的数据
+	single, err := buffer.NewPoolWithOptions[int](1, total,
+		buffer.WithOverflowPolicy[int](buffer.OverflowDropOldest, nil))
+	if err != nil {
+		glog.Error("NewPoolWithOptions err:", err)
+		return
+	}
+	testDropOldest("single", single, extra, []int{3, 4, 5, 6, 7, 8, 9, 10})
+}
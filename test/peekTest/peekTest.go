@@ -0,0 +1,144 @@
+package main
+
+import (
+	"buffer"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+func init() {
+	glog.MaxSize = 1024 * 1024 * 100    //最大100M
+	flag.Set("alsologtostderr", "true") // 日志写入文件的同时，输出到stderr
+	flag.Set("log_dir", "./log")        // 日志文件保存目录
+	flag.Set("v", "1")                  // 配置V输出的等级。
+	flag.Parse()
+}
+
+const (
+	bufferCap = 8
+	total     = 5
+	levels    = 3
+)
+
+// testClosedPeek 已关闭的缓冲器和 Get 一样可以查看剩余的数据 取完后返回ErrClosedBuffer
+func testClosedPeek() {
+	buf, err := buffer.NewBufferOf[int](bufferCap)
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	for i := 0; i < total; i++ {
+		buf.Put(i)
+	}
+	buf.Close()
+
+	for i := 0; i < total; i++ {
+		peeked, err := buf.Peek()
+		if err != nil || peeked != i {
+			glog.Errorf("closed peek %d got %d err:%v", i, peeked, err)
+			return
+		}
+		data, err := buf.Get()
+		if err != nil || data != peeked {
+			glog.Errorf("closed get %d got %d err:%v", i, data, err)
+			return
+		}
+	}
+	if _, err = buf.Peek(); err != buffer.ErrClosedBuffer {
+		glog.Errorf("closed peek after drained err:%v want %v", err, buffer.ErrClosedBuffer)
+		return
+	}
+	glog.Infof("closed peek ok err after drained:%v", err)
+}
+
+// testPeekOrder 查看一部分数据后 Put Get 的顺序和容量不变
+func testPeekOrder() {
+	buf, err := buffer.NewBufferOf[int](bufferCap)
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	defer buf.Close()
+
+	for i := 0; i < bufferCap-1; i++ {
+		buf.Put(i)
+	}
+	peeked, _ := buf.PeekN(2)
+	buf.Put(bufferCap - 1)
+	if ok, err := buf.Put(bufferCap); ok || err != buffer.ErrBufferOverload {
+		glog.Errorf("put to a full buffer after peek ok:%v err:%v", ok, err)
+		return
+	}
+	got, _ := buf.GetBatch(bufferCap)
+	if fmt.Sprint(peeked) != "[0 1]" || fmt.Sprint(got) != "[0 1 2 3 4 5 6 7]" {
+		glog.Errorf("peek order peeked:%v got:%v", peeked, got)
+		return
+	}
+	glog.Infof("peek order peeked:%v got:%v", peeked, got)
+}
+
+// testPriorityPeek 优先级模式下 PeekN 的顺序和 Get 的顺序一致
+func testPriorityPeek() {
+	pool, err := buffer.NewPoolWithOptions[int](4, 3,
+		buffer.WithPriority[int](levels, func(data int) int { return data % levels }, 0))
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	defer pool.Close()
+
+	for _, data := range []int{5, 4, 2, 1, 0, 8, 7, 3, 6} {
+		pool.Put(data)
+	}
+	peeked, _ := pool.PeekN(bufferCap * 2)
+	var got []int
+	for {
+		data, err := pool.TryGet()
+		if err != nil {
+			break
+		}
+		got = append(got, data)
+	}
+	if fmt.Sprint(peeked) != fmt.Sprint(got) {
+		glog.Errorf("priority peek:%v get:%v", peeked, got)
+		return
+	}
+	glog.Infof("priority peek:%v get:%v", peeked, got)
+}
+
+// testTTLPeek 过期缓冲池的 PeekN 跳过过期的数据 前面的数据都过期时扩大查看的范围
+func testTTLPeek() {
+	inner, err := buffer.NewOrderedPoolOf[buffer.TTLItem[int]](1, bufferCap*2)
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	pool := buffer.NewTTLPoolOf(inner, 0, 0, nil)
+	defer pool.Close()
+
+	for i := 0; i < bufferCap; i++ {
+		pool.PutWithTTL(-i, time.Millisecond)
+	}
+	for i := 0; i < total; i++ {
+		pool.Put(i)
+	}
+	time.Sleep(2 * time.Millisecond)
+
+	peeked, err := pool.PeekN(2)
+	if err != nil || fmt.Sprint(peeked) != "[0 1]" || pool.Total() != bufferCap+total {
+		glog.Errorf("ttl peek:%v err:%v total:%d", peeked, err, pool.Total())
+		return
+	}
+	glog.Infof("ttl peek:%v total:%d", peeked, pool.Total())
+}
+
+func main() {
+	defer glog.Flush()
+	testClosedPeek()
+	testPeekOrder()
+	testPriorityPeek()
+	testTTLPeek()
+}
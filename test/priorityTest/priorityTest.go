@@ -0,0 +1,98 @@
+package main
+
+import (
+	"buffer"
+	"flag"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+func init() {
+	glog.MaxSize = 1024 * 1024 * 100    //最大100M
+	flag.Set("alsologtostderr", "true") // 日志写入文件的同时，输出到stderr
+	flag.Set("log_dir", "./log")        // 日志文件保存目录
+	flag.Set("v", "1")                  // 配置V输出的等级。
+	flag.Parse()
+}
+
+const (
+	poolCap   = 2
+	bufferCap = 4
+	levels    = 2
+	high      = -1
+	low       = 1
+	blocked   = 1000
+	holdTime  = 50 * time.Millisecond
+)
+
+// level 负数为高优先级 其它为低优先级
+func level(data int) int {
+	if data < 0 {
+		return 0
+	}
+	return 1
+}
+
+// gateBuffer 放入blocked时等到gate被关闭 用来让 Put 一直占用一个缓冲器
+type gateBuffer struct {
+	*buffer.PriorityBufferOf[int]
+	gate chan struct{}
+}
+
+func (buf *gateBuffer) Put(data int) (ok bool, err error) {
+	if data == blocked {
+		<-buf.gate
+	}
+	return buf.PriorityBufferOf.Put(data)
+}
+
+// testStrict 高优先级数据所在的缓冲器正在被 Put 占用时 Get 等它归还 而不是先取其它缓冲器中的低优先级数据
+func testStrict() {
+	gate := make(chan struct{})
+	factory := func(size uint32) (buffer.IBufferOf[int], error) {
+		buf, err := buffer.NewPriorityBufferOf[int](size, levels, level, 0)
+		if err != nil {
+			return nil, err
+		}
+		return &gateBuffer{buf, gate}, nil
+	}
+	pool, err := buffer.NewPoolWithOptions[int](poolCap, bufferCap,
+		buffer.WithPriority[int](levels, level, 0), buffer.WithBufferFactory[int](factory),
+		buffer.WithInitBuffers[int](poolCap))
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	defer pool.Close()
+
+	//缓冲器按取还的顺序轮换 high放入第一个缓冲器 low放入第二个 blocked又轮到第一个
+	pool.TryPut(high)
+	pool.TryPut(low)
+	go pool.Put(blocked)
+	time.Sleep(holdTime)
+	go func() {
+		time.Sleep(holdTime)
+		close(gate)
+	}()
+
+	var got []int
+	for i := 0; i < 3; i++ {
+		data, err := pool.Get()
+		if err != nil {
+			glog.Errorf("get err:%v", err)
+			return
+		}
+		got = append(got, data)
+	}
+	if got[0] != high {
+		glog.Errorf("priority get:%v want %d first", got, high)
+		return
+	}
+	glog.Infof("priority get:%v", got)
+}
+
+func main() {
+	defer glog.Flush()
+	testStrict()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"buffer"
+	"flag"
+
+	"github.com/golang/glog"
+)
+
+func init() {
+	glog.MaxSize = 1024 * 1024 * 100    //最大100M
+	flag.Set("alsologtostderr", "true") // 日志写入文件的同时，输出到stderr
+	flag.Set("log_dir", "./log")        // 日志文件保存目录
+	flag.Set("v", "1")                  // 配置V输出的等级。
+	flag.Parse()
+}
+
+const (
+	poolCap   = 2
+	bufferCap = 4
+	growCap   = 8
+)
+
+// fill 非阻塞地放入数据直到放不下 返回放入的数量
+func fill(pool buffer.IPoolOf[int], start int) int {
+	n := 0
+	for {
+		if ok, _ := pool.TryPut(start + n); !ok {
+			return n
+		}
+		n++
+	}
+}
+
+// testResize NewPoolOf创建的缓冲池运行时调大后可以新建缓冲器 调小后不丢数据
+func testResize(name string, pool *buffer.BufferPoolOf[int]) {
+	defer pool.Close()
+
+	put := fill(pool, 0)
+	if put != poolCap*bufferCap {
+		glog.Errorf("%s put before grow:%d want %d", name, put, poolCap*bufferCap)
+		return
+	}
+	if err := pool.SetPoolCap(growCap); err != nil {
+		glog.Errorf("%s grow err:%v", name, err)
+		return
+	}
+	more := fill(pool, put)
+	if more != (growCap-poolCap)*bufferCap || pool.Len() != growCap {
+		glog.Errorf("%s put after grow:%d len:%d want %d %d", name, more, pool.Len(),
+			(growCap-poolCap)*bufferCap, growCap)
+		return
+	}
+	if err := pool.SetPoolCap(poolCap); err != nil {
+		glog.Errorf("%s shrink err:%v", name, err)
+		return
+	}
+	seen := make(map[int]bool)
+	for {
+		data, err := pool.TryGet()
+		if err != nil {
+			break
+		}
+		seen[data] = true
+	}
+	if len(seen) != put+more || pool.Len() > poolCap {
+		glog.Errorf("%s got %d want %d len:%d after shrink", name, len(seen), put+more, pool.Len())
+		return
+	}
+	glog.Infof("%s grow to %d and shrink back got:%d %v", name, growCap, len(seen), pool)
+}
+
+func main() {
+	defer glog.Flush()
+	pool, err := buffer.NewPoolOf[int](poolCap, bufferCap)
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	testResize("normal", pool)
+
+	ordered, err := buffer.NewOrderedPoolOf[int](poolCap, bufferCap)
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	testResize("ordered", ordered)
+
+	//用WithMaxPoolCap限制上限后不能超过它
+	limited, err := buffer.NewPoolWithOptions[int](poolCap, bufferCap, buffer.WithMaxPoolCap[int](poolCap))
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	defer limited.Close()
+	if err = limited.SetPoolCap(growCap); err == nil {
+		glog.Errorf("grow beyond maxPoolCap(%d) succeeded", poolCap)
+		return
+	}
+	glog.Infof("grow beyond maxPoolCap err:%v", err)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"buffer"
+	"flag"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+func init() {
+	glog.MaxSize = 1024 * 1024 * 100    //最大100M
+	flag.Set("alsologtostderr", "true") // 日志写入文件的同时，输出到stderr
+	flag.Set("log_dir", "./log")        // 日志文件保存目录
+	flag.Set("v", "1")                  // 配置V输出的等级。
+	flag.Parse()
+}
+
+const total = 5120000
+
+// benchBuffer producers个协程存 consumers个协程取 一共total个数据 返回耗时
+func benchBuffer(buf buffer.IBufferOf[int], producers, consumers int) time.Duration {
+	var wg sync.WaitGroup
+	var got int64
+	now := time.Now()
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < total/producers; i++ {
+				for ok, _ := buf.Put(i); !ok; ok, _ = buf.Put(i) {
+					runtime.Gosched()
+				}
+			}
+		}()
+	}
+	for c := 0; c < consumers; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for atomic.LoadInt64(&got) < total {
+				if _, err := buf.Get(); err != nil {
+					runtime.Gosched()
+					continue
+				}
+				atomic.AddInt64(&got, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	return time.Now().Sub(now)
+}
+
+// benchPool 同benchBuffer 数据经过缓冲池存取
+func benchPool(pool buffer.IPoolOf[int], producers, consumers int) time.Duration {
+	var wg sync.WaitGroup
+	var got int64
+	now := time.Now()
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < total/producers; i++ {
+				pool.Put(i)
+			}
+		}()
+	}
+	for c := 0; c < consumers; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for atomic.LoadInt64(&got) < total {
+				if _, err := pool.GetTimeout(time.Millisecond * 100); err == nil {
+					atomic.AddInt64(&got, 1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	pool.Close()
+	return time.Now().Sub(now)
+}
+
+func main() {
+	cases := [][2]int{{1, 1}, {1, 10}, {10, 1}, {10, 10}}
+	for _, c := range cases {
+		chanBuf, _ := buffer.NewBufferOf[int](4096)
+		ringBuf, _ := buffer.NewRingBufferOf[int](4096)
+		glog.Infof("buffer %d存 %d取 %d  chan:%v ring:%v", c[0], c[1], total,
+			benchBuffer(chanBuf, c[0], c[1]), benchBuffer(ringBuf, c[0], c[1]))
+	}
+
+	for _, c := range cases {
+		chanPool, _ := buffer.NewPoolOf[int](10, 4096)
+		ringPool, _ := buffer.NewPoolWithOptions[int](10, 4096,
+			buffer.WithBufferFactory[int](buffer.NewRingBufferOf[int]))
+		glog.Infof("pool %d存 %d取 %d  chan:%v ring:%v", c[0], c[1], total,
+			benchPool(chanPool, c[0], c[1]), benchPool(ringPool, c[0], c[1]))
+	}
+}
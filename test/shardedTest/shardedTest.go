@@ -0,0 +1,64 @@
+package main
+
+import (
+	"buffer"
+	"flag"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+func init() {
+	glog.MaxSize = 1024 * 1024 * 100    //最大100M
+	flag.Set("alsologtostderr", "true") // 日志写入文件的同时，输出到stderr
+	flag.Set("log_dir", "./log")        // 日志文件保存目录
+	flag.Set("v", "1")                  // 配置V输出的等级。
+	flag.Parse()
+}
+
+const total = 5120000
+
+// bench producers个协程存 consumers个协程取 一共total个数据 返回耗时
+func bench(pool buffer.IPoolOf[int], producers, consumers int) time.Duration {
+	var wg sync.WaitGroup
+	var got int64
+	now := time.Now()
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < total/producers; i++ {
+				pool.Put(i)
+			}
+		}()
+	}
+	for c := 0; c < consumers; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for atomic.LoadInt64(&got) < total {
+				if _, err := pool.GetTimeout(time.Millisecond * 100); err == nil {
+					atomic.AddInt64(&got, 1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return time.Now().Sub(now)
+}
+
+func main() {
+	shards := uint32(runtime.NumCPU())
+	for _, c := range [][2]int{{1, 1}, {10, 10}} {
+		pool, _ := buffer.NewPoolOf[int](10, 4096)
+		glog.Infof("pool %d存 %d取 %d  %v %v", c[0], c[1], total, bench(pool, c[0], c[1]), pool)
+		pool.Close()
+
+		sharded, _ := buffer.NewShardedPoolOf[int](shards, 10, 4096)
+		glog.Infof("sharded %d存 %d取 %d  %v %v", c[0], c[1], total, bench(sharded, c[0], c[1]), sharded)
+		sharded.Close()
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"buffer"
+	"bytes"
+	"flag"
+
+	"github.com/golang/glog"
+)
+
+func init() {
+	glog.MaxSize = 1024 * 1024 * 100    //最大100M
+	flag.Set("alsologtostderr", "true") // 日志写入文件的同时，输出到stderr
+	flag.Set("log_dir", "./log")        // 日志文件保存目录
+	flag.Set("v", "1")                  // 配置V输出的等级。
+	flag.Parse()
+}
+
+const (
+	poolCap   = 3
+	bufferCap = 4
+	total     = 10
+)
+
+// snapshot 创建先进先出模式的缓冲池 放入0到total-1 返回它的快照
+func snapshot() ([]byte, error) {
+	pool, err := buffer.NewPoolWithOptions[int](poolCap, bufferCap, buffer.WithOrdered[int]())
+	if err != nil {
+		return nil, err
+	}
+	defer pool.Close()
+	for i := 0; i < total; i++ {
+		pool.Put(i)
+	}
+	var w bytes.Buffer
+	n, err := pool.Snapshot(&w, buffer.JSONCodec[int]{})
+	if err != nil {
+		return nil, err
+	}
+	if n != total || pool.Total() != total {
+		glog.Errorf("snapshot n:%d total:%d want %d", n, pool.Total(), total)
+	}
+	return w.Bytes(), nil
+}
+
+// testRestore 快照恢复到新的缓冲池 数量和顺序不变
+func testRestore(data []byte) {
+	pool, err := buffer.NewPoolWithOptions[int](poolCap, bufferCap, buffer.WithOrdered[int]())
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	defer pool.Close()
+	n, err := pool.Restore(bytes.NewReader(data), buffer.JSONCodec[int]{})
+	if err != nil || n != total || pool.Total() != total {
+		glog.Errorf("restore n:%d err:%v total:%d want %d", n, err, pool.Total(), total)
+		return
+	}
+	for i := 0; i < total; i++ {
+		if got, err := pool.TryGet(); err != nil || got != i {
+			glog.Errorf("get %d after restore got %d err:%v", i, got, err)
+			return
+		}
+	}
+	glog.Infof("restore ok %v", pool)
+}
+
+// testBadSnapshot 文件头错误 数据损坏或者被截断的快照返回ErrBadSnapshot
+func testBadSnapshot(data []byte) {
+	magic := append([]byte(nil), data...)
+	magic[0] = 'X'
+	corrupted := append([]byte(nil), data...)
+	corrupted[24] ^= 0xff //第一条数据 跳过16字节的文件头和8字节的记录头
+	truncated := data[:len(data)-1]
+	for name, bad := range map[string][]byte{"magic": magic, "corrupted": corrupted, "truncated": truncated} {
+		pool, err := buffer.NewPoolOf[int](poolCap, bufferCap)
+		if err != nil {
+			glog.Error(err)
+			return
+		}
+		if _, err = pool.Restore(bytes.NewReader(bad), buffer.JSONCodec[int]{}); err != buffer.ErrBadSnapshot {
+			glog.Errorf("restore %s snapshot err:%v want ErrBadSnapshot", name, err)
+		}
+		pool.Close()
+	}
+	glog.Info("bad snapshot ok")
+}
+
+// testOverload 恢复到容量更小的缓冲池 放满后返回ErrBufferOverload
+func testOverload(data []byte) {
+	pool, err := buffer.NewPoolWithOptions[int](1, bufferCap, buffer.WithOrdered[int]())
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	defer pool.Close()
+	n, err := pool.Restore(bytes.NewReader(data), buffer.JSONCodec[int]{})
+	if err != buffer.ErrBufferOverload || n != bufferCap || pool.Total() != bufferCap {
+		glog.Errorf("restore into smaller pool n:%d err:%v total:%d want %d", n, err, pool.Total(), bufferCap)
+		return
+	}
+	glog.Infof("overload ok %v", pool)
+}
+
+// testClosed 已关闭的缓冲池不能生成快照
+func testClosed() {
+	pool, err := buffer.NewPoolOf[int](poolCap, bufferCap)
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	pool.Close()
+	var w bytes.Buffer
+	if _, err = pool.Snapshot(&w, buffer.JSONCodec[int]{}); err != buffer.ErrClosedBufferPool {
+		glog.Errorf("snapshot closed pool err:%v want ErrClosedBufferPool", err)
+		return
+	}
+	glog.Info("closed ok")
+}
+
+func main() {
+	defer glog.Flush()
+	data, err := snapshot()
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	testRestore(data)
+	testBadSnapshot(data)
+	testOverload(data)
+	testClosed()
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"buffer"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/golang/glog"
+)
+
+func init() {
+	glog.MaxSize = 1024 * 1024 * 100    //最大100M
+	flag.Set("alsologtostderr", "true") // 日志写入文件的同时，输出到stderr
+	flag.Set("log_dir", "./log")        // 日志文件保存目录
+	flag.Set("v", "1")                  // 配置V输出的等级。
+	flag.Parse()
+}
+
+const (
+	poolCap   = 1
+	bufferCap = 2
+	total     = 10
+	consumed  = 5
+)
+
+// open 打开dir下的溢出日志并创建使用它的缓冲池
+func open(dir string) (*buffer.BufferPoolOf[int], error) {
+	spill, err := buffer.OpenSpillLog[int](dir, 0, buffer.GobCodec[int]{})
+	if err != nil {
+		return nil, err
+	}
+	return buffer.NewPoolWithOptions[int](poolCap, bufferCap, buffer.WithSpill[int](spill))
+}
+
+// testOrder 放不下的数据写入溢出日志 之后的数据也写入溢出日志 Get 按放入的顺序取到所有数据
+func testOrder(dir string) {
+	pool, err := open(dir)
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	defer pool.Close()
+
+	for i := 0; i < total; i++ {
+		if ok, err := pool.Put(i); !ok || err != nil {
+			glog.Errorf("put %d ok:%v err:%v", i, ok, err)
+			return
+		}
+	}
+	if pool.SpillLen() != total-bufferCap || pool.Spilled() != total-bufferCap {
+		glog.Errorf("spillLen:%d spilled:%d want %d", pool.SpillLen(), pool.Spilled(), total-bufferCap)
+		return
+	}
+	for i := 0; i < total; i++ {
+		data, err := pool.TryGet()
+		if err != nil || data != i {
+			glog.Errorf("get %d got %d err:%v", i, data, err)
+			return
+		}
+	}
+	if _, err = pool.TryGet(); err != buffer.ErrBufferEmpty || pool.SpillLen() != 0 {
+		glog.Errorf("get after drained err:%v spillLen:%d", err, pool.SpillLen())
+		return
+	}
+	glog.Infof("spill order ok %v", pool)
+}
+
+// testRestart 取走一部分后关闭 重新打开同一目录 溢出日志中没有确认的数据按顺序放回 至少一次
+// 直接放入内存的0 1随缓冲池关闭丢弃 2 3放回内存后被取完 已经确认
+// 4 5一起放回内存 4被取走但5还在内存中 没有确认 所以重启后从4开始再次放回
+func testRestart(dir string) {
+	pool, err := open(dir)
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	for i := 0; i < total; i++ {
+		pool.Put(i)
+	}
+	for i := 0; i < consumed; i++ {
+		if data, err := pool.TryGet(); err != nil || data != i {
+			glog.Errorf("get %d before restart got %d err:%v", i, data, err)
+			pool.Close()
+			return
+		}
+	}
+	pool.Close()
+
+	pool, err = open(dir)
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	defer pool.Close()
+	var got []int
+	for {
+		data, err := pool.TryGet()
+		if err != nil {
+			break
+		}
+		got = append(got, data)
+	}
+	if fmt.Sprint(got) != "[4 5 6 7 8 9]" {
+		glog.Errorf("replay after restart:%v want [4 5 6 7 8 9]", got)
+		return
+	}
+	glog.Infof("replay after restart:%v", got)
+}
+
+func main() {
+	defer glog.Flush()
+	for _, test := range []func(dir string){testOrder, testRestart} {
+		dir, err := os.MkdirTemp("", "spillTest")
+		if err != nil {
+			glog.Error(err)
+			return
+		}
+		test(dir)
+		os.RemoveAll(dir)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"buffer"
+	"context"
+	"flag"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+func init() {
+	glog.MaxSize = 1024 * 1024 * 100    //最大100M
+	flag.Set("alsologtostderr", "true") // 日志写入文件的同时，输出到stderr
+	flag.Set("log_dir", "./log")        // 日志文件保存目录
+	flag.Set("v", "1")                  // 配置V输出的等级。
+	flag.Parse()
+}
+
+const (
+	poolCap   = 1
+	bufferCap = 2
+	total     = 10
+)
+
+// failed 收集onError收到的数据和错误
+type failed struct {
+	lock  sync.Mutex
+	items []int
+	errs  []error
+}
+
+func (f *failed) onError(data int, err error) {
+	f.lock.Lock()
+	f.items = append(f.items, data)
+	f.errs = append(f.errs, err)
+	f.lock.Unlock()
+}
+
+// testOutCancel Out已经取出的数据在ctx结束时放不回已满的缓冲池 交给onError而不是丢失
+func testOutCancel() {
+	pool, err := buffer.NewPoolOf[int](poolCap, bufferCap)
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	defer pool.Close()
+
+	var f failed
+	ctx, cancel := context.WithCancel(context.Background())
+	pool.Put(0)
+	out := pool.Out(ctx, f.onError)
+	//等后台协程取出0 再把缓冲池放满
+	time.Sleep(10 * time.Millisecond)
+	for i := 1; i <= bufferCap; i++ {
+		pool.Put(i)
+	}
+	cancel()
+	for range out {
+	}
+	if len(f.items) != 1 || f.items[0] != 0 || f.errs[0] != buffer.ErrBufferOverload {
+		glog.Errorf("out cancel onError items:%v errs:%v want [0] overload", f.items, f.errs)
+		return
+	}
+	glog.Infof("out cancel onError items:%v errs:%v total(%d)", f.items, f.errs, pool.Total())
+}
+
+// testInOverflow In放入时被OverflowDropNewest策略丢弃的数据交给onError
+func testInOverflow() {
+	pool, err := buffer.NewPoolWithOptions[int](poolCap, bufferCap,
+		buffer.WithOverflowPolicy[int](buffer.OverflowDropNewest, nil))
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	defer pool.Close()
+
+	var f failed
+	in, done := pool.In(context.Background(), f.onError)
+	for i := 0; i < total; i++ {
+		select {
+		case in <- i:
+		case <-done:
+		}
+	}
+	close(in)
+	<-done
+	if pool.Total() != bufferCap || len(f.items) != total-bufferCap {
+		glog.Errorf("in overflow total(%d) onError items:%v", pool.Total(), f.items)
+		return
+	}
+	glog.Infof("in overflow total(%d) onError items:%v", pool.Total(), f.items)
+}
+
+func main() {
+	defer glog.Flush()
+	testOutCancel()
+	testInOverflow()
+}
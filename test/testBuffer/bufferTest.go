@@ -2,8 +2,9 @@
 package main
 
 import (
-	"flag"
 	"buffer"
+	"context"
+	"flag"
 	"time"
 
 	"github.com/golang/glog"
@@ -18,7 +19,7 @@ func init() {
 }
 
 func main() {
-	pool, err := buffer.NewPool(10, 4096)
+	pool, err := buffer.NewPoolOf[int](10, 4096)
 	if pool == nil {
 		glog.Info(err)
 		return
@@ -37,15 +38,12 @@ func main() {
 	for i := 0; i < 1; i++ {
 		go func() {
 			glog.Info("Get Data begin..............")
-			ticker := time.NewTicker(time.Second * 150)
-			defer ticker.Stop()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*150)
+			defer cancel()
 			for {
-				select {
-				case <-ticker.C:
-					glog.Info("Get Data End:", pool)
+				if _, err := pool.GetContext(ctx); err != nil {
+					glog.Info("Get Data End:", pool, " err:", err)
 					return
-				default:
-					pool.Get()
 				}
 			}
 		}()
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"buffer"
+	"flag"
+
+	"github.com/golang/glog"
+)
+
+func init() {
+	glog.MaxSize = 1024 * 1024 * 100    //最大100M
+	flag.Set("alsologtostderr", "true") // 日志写入文件的同时，输出到stderr
+	flag.Set("log_dir", "./log")        // 日志文件保存目录
+	flag.Set("v", "1")                  // 配置V输出的等级。
+	flag.Parse()
+}
+
+const (
+	dir   = "./wal"
+	total = 100000
+)
+
+// 每次运行先取出上次运行留下的数据 检查顺序 再边放入边取出total/2个数据后直接退出
+// 多次运行 每次都应该按顺序取到上次退出时还没有取出的数据
+func main() {
+	config := buffer.WALConfig{SegmentSize: 1 << 20, Sync: buffer.SyncBatch, SyncBatch: 1000}
+	pool, err := buffer.OpenWALPoolOf[int](dir, 10, 1024, buffer.GobCodec[int]{}, config)
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	defer pool.Close()
+	glog.Infof("open %v", pool)
+
+	var left, disorder int
+	last := -1
+	for {
+		data, err := pool.TryGet()
+		if err != nil {
+			break
+		}
+		if last >= 0 && data != last+1 {
+			disorder++
+		}
+		last = data
+		left++
+	}
+	glog.Infof("left(%d) disorder(%d) %v", left, disorder, pool)
+
+	go func() {
+		for i := 0; i < total; i++ {
+			if _, err := pool.Put(i); err != nil {
+				glog.Errorf("put %d err:%v", i, err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < total/2; i++ {
+		if _, err := pool.Get(); err != nil {
+			glog.Errorf("get %d err:%v", i, err)
+			return
+		}
+	}
+	glog.Infof("got %d %v err:%v", total/2, pool, pool.Err())
+	glog.Flush()
+}